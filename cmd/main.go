@@ -1,5 +1,8 @@
 package main
 
+import "github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+
 func main() {
+	runners.RunIsolationHelper()
 	Execute()
 }
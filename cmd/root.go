@@ -1,11 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/replay"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/server"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +32,7 @@ func Execute() {
 
 func init() {
 	rootCmd.PersistentFlags().StringP("config", "c", "", "config file path (for logging configuration)")
+	rootCmd.PersistentFlags().String("profile", "", "tool profile to expose: full, readonly or no-exec (overrides tools.profile)")
 
 	stdioCmd := &cobra.Command{
 		Use:   "stdio",
@@ -31,15 +40,47 @@ func init() {
 		RunE:  runStdioServer,
 	}
 	rootCmd.AddCommand(stdioCmd)
+
+	httpCmd := &cobra.Command{
+		Use:   "http",
+		Short: "Run over streamable HTTP transport (for shared, long-lived servers)",
+		RunE:  runHTTPServer,
+	}
+	httpCmd.Flags().String("addr", "127.0.0.1:8080", "address to listen on")
+	httpCmd.Flags().String("base-path", "/mcp", "URL path the MCP endpoint is served under")
+	httpCmd.Flags().Duration("shutdown-timeout", 10*time.Second, "time to wait for in-flight requests on shutdown")
+	rootCmd.AddCommand(httpCmd)
+
+	callCmd := &cobra.Command{
+		Use:          "call <tool>",
+		Short:        "Invoke a tool in-process and print its structured output",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runCall,
+	}
+	callCmd.Flags().String("json", "{}", `tool arguments as a JSON object ("-" reads them from stdin)`)
+	rootCmd.AddCommand(callCmd)
+
+	replayCmd := &cobra.Command{
+		Use:          "replay <audit.jsonl>",
+		Short:        "Re-execute a recorded audit log and diff the outputs against the recording",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         runReplay,
+	}
+	replayCmd.Flags().String("target", ".", "directory to replay the calls in; it becomes the workspace root")
+	replayCmd.Flags().String("source-root", "", "directory the calls were recorded in; rewritten to --target in arguments and outputs")
+	replayCmd.Flags().String("session", "", "only replay calls from this session id")
+	rootCmd.AddCommand(replayCmd)
 }
 
-func runStdioServer(cmd *cobra.Command, args []string) error {
+func loadConfig(cmd *cobra.Command) *config.Config {
 	configPath, _ := cmd.Flags().GetString("config")
 
 	cfg, err := config.LoadConfig(configPath)
 	if err != nil {
-		fmt.Printf("Warning: Failed to load config: %v\n", err)
-		fmt.Println("Server will start with default configuration.")
+		fmt.Fprintf(os.Stderr, "Warning: Failed to load config: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Server will start with default configuration.")
 		cfg = &config.Config{
 			Logging: config.LoggingConfig{
 				Level:      "INFO",
@@ -50,8 +91,208 @@ func runStdioServer(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		cfg.Tools.Profile = profile
+	}
+
+	return cfg
+}
+
+func runStdioServer(cmd *cobra.Command, args []string) error {
+	cfg := loadConfig(cmd)
+
 	return server.RunStdioServer(server.StdioServerConfig{
 		Version: "v0.1.0",
 		Config:  cfg,
 	})
 }
+
+func runHTTPServer(cmd *cobra.Command, args []string) error {
+	cfg := loadConfig(cmd)
+
+	addr, _ := cmd.Flags().GetString("addr")
+	basePath, _ := cmd.Flags().GetString("base-path")
+	shutdownTimeout, _ := cmd.Flags().GetDuration("shutdown-timeout")
+
+	return server.RunHTTPServer(server.HTTPServerConfig{
+		Version:         "v0.1.0",
+		Config:          cfg,
+		Addr:            addr,
+		BasePath:        basePath,
+		ShutdownTimeout: shutdownTimeout,
+	})
+}
+
+// readBeforeModify names the path argument of tools that only modify files
+// the session has read. A one-shot call has no earlier read, so call reads an
+// existing file in the same session first.
+var readBeforeModify = map[string]string{
+	tools.EditToolName:         "file_path",
+	tools.MultiEditToolName:    "file_path",
+	tools.WriteToolName:        "file_path",
+	tools.NotebookEditToolName: "notebook_path",
+}
+
+func runCall(cmd *cobra.Command, args []string) error {
+	cfg := loadConfig(cmd)
+	cfg.Logging.Console = false
+
+	rawArgs, _ := cmd.Flags().GetString("json")
+	if rawArgs == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read arguments from stdin: %w", err)
+		}
+		rawArgs = string(data)
+	}
+	if !json.Valid([]byte(rawArgs)) {
+		return fmt.Errorf("--json is not valid JSON")
+	}
+
+	ctx := cmd.Context()
+	client, err := server.NewInProcessClient(ctx, server.MCPServerConfig{
+		Version: "v0.1.0",
+		Config:  cfg,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := readFirst(cmd, client, args[0], rawArgs); err != nil {
+		return err
+	}
+
+	result, err := client.Call(ctx, args[0], json.RawMessage(rawArgs))
+	if err != nil {
+		return err
+	}
+
+	if result.IsError {
+		printErrorContent(result)
+		return fmt.Errorf("tool %s failed", args[0])
+	}
+
+	if result.StructuredContent != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(result.StructuredContent)
+	}
+
+	for _, content := range result.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			fmt.Println(text.Text)
+		}
+	}
+	return nil
+}
+
+func readFirst(cmd *cobra.Command, client *server.InProcessClient, tool, rawArgs string) error {
+	key, ok := readBeforeModify[tool]
+	if !ok {
+		return nil
+	}
+
+	var toolArgs map[string]any
+	if err := json.Unmarshal([]byte(rawArgs), &toolArgs); err != nil {
+		return nil
+	}
+	path, _ := toolArgs[key].(string)
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	readArgs, err := json.Marshal(map[string]string{"file_path": path})
+	if err != nil {
+		return err
+	}
+	result, err := client.Call(cmd.Context(), tools.ReadToolName, readArgs)
+	if err != nil {
+		return err
+	}
+	if result.IsError {
+		printErrorContent(result)
+		return fmt.Errorf("failed to read %s before %s", path, tool)
+	}
+	return nil
+}
+
+func printErrorContent(result *mcp.CallToolResult) {
+	for _, content := range result.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			fmt.Fprintln(os.Stderr, text.Text)
+		}
+	}
+}
+
+func runReplay(cmd *cobra.Command, args []string) error {
+	cfg := loadConfig(cmd)
+	cfg.Logging.Console = false
+	cfg.Audit.OutputFile = ""
+
+	target, _ := cmd.Flags().GetString("target")
+	sourceRoot, _ := cmd.Flags().GetString("source-root")
+	session, _ := cmd.Flags().GetString("session")
+
+	target, err := filepath.Abs(target)
+	if err != nil {
+		return fmt.Errorf("failed to resolve target: %w", err)
+	}
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		return fmt.Errorf("target is not a directory: %s", target)
+	}
+
+	entries, err := audit.ReadEntries(args[0])
+	if err != nil {
+		return err
+	}
+
+	if cfg.Logging.OutputFile != "" {
+		if cfg.Logging.OutputFile, err = filepath.Abs(cfg.Logging.OutputFile); err != nil {
+			return fmt.Errorf("failed to resolve log file: %w", err)
+		}
+	}
+
+	// Relative paths and default working directories in the recording are
+	// resolved against the target.
+	if err := os.Chdir(target); err != nil {
+		return fmt.Errorf("failed to enter target directory: %w", err)
+	}
+	cfg.Workspace.Roots = []string{target}
+
+	ctx := cmd.Context()
+	client, err := server.NewInProcessClient(ctx, server.MCPServerConfig{
+		Version: "v0.1.0",
+		Config:  cfg,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	summary, err := replay.Run(ctx, client, entries, replay.Options{
+		Target:     target,
+		SourceRoot: sourceRoot,
+		Session:    session,
+		Out:        os.Stdout,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nReplayed %d call(s): %d matched, %d differed\n", summary.Calls, summary.Matched, summary.Diffs)
+	if summary.Unverified > 0 {
+		fmt.Printf("WARNING: %d successful call(s) had no recorded output, so only their status, exit code and file hashes were compared; record with audit.record_output to compare outputs\n", summary.Unverified)
+	}
+	if summary.Redacted > 0 {
+		fmt.Printf("%d call(s) had redacted arguments and were replayed with placeholders\n", summary.Redacted)
+	}
+	if summary.Diffs > 0 {
+		return fmt.Errorf("%d call(s) differed from the recording", summary.Diffs)
+	}
+	return nil
+}
@@ -3,12 +3,13 @@ module github.com/AbdelilahOu/CodeToolsMcp
 go 1.25.1
 
 require (
+	github.com/google/jsonschema-go v0.3.0
 	github.com/modelcontextprotocol/go-sdk v0.7.0
 	github.com/spf13/cobra v1.10.1
+	golang.org/x/image v0.32.0
 )
 
 require (
-	github.com/google/jsonschema-go v0.3.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
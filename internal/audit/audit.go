@@ -0,0 +1,290 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+
+	// DirectoryHash marks a path that was a directory rather than a file.
+	DirectoryHash = "dir"
+)
+
+type Entry struct {
+	Timestamp   time.Time       `json:"timestamp"`
+	SessionID   string          `json:"session_id,omitempty"`
+	CallID      string          `json:"call_id"`
+	Tool        string          `json:"tool"`
+	Arguments   json.RawMessage `json:"arguments"`
+	ResultBytes int             `json:"result_bytes"`
+	Output      json.RawMessage `json:"output,omitempty"`
+	Status      string          `json:"status"`
+	ExitCode    *int            `json:"exit_code,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	DurationMS  float64         `json:"duration_ms"`
+	Files       []FileChange    `json:"files,omitempty"`
+}
+
+// FileChange holds the sha256 of a touched path before and after the call.
+// An empty hash means the path did not exist.
+type FileChange struct {
+	Path   string `json:"path"`
+	Before string `json:"before_sha256,omitempty"`
+	After  string `json:"after_sha256,omitempty"`
+}
+
+// ExitStatus is implemented by tool outputs that carry a process exit code.
+type ExitStatus interface {
+	ExitStatus() int
+}
+
+type Auditor struct {
+	mu           sync.Mutex
+	file         *os.File
+	redactor     *Redactor
+	recordOutput bool
+}
+
+var globalAuditor *Auditor
+
+func Initialize(cfg config.AuditConfig) error {
+	if cfg.OutputFile == "" {
+		globalAuditor = nil
+		return nil
+	}
+	auditor, err := NewAuditor(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	globalAuditor = auditor
+	return nil
+}
+
+func NewAuditor(cfg config.AuditConfig) (*Auditor, error) {
+	redactor, err := NewRedactor(cfg.RedactEnvKeys, cfg.RedactPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(cfg.OutputFile)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(cfg.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &Auditor{file: file, redactor: redactor, recordOutput: cfg.RecordOutput}, nil
+}
+
+// ReadEntries loads every entry from a JSONL audit log.
+func ReadEntries(path string) ([]Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit entry on line %d: %w", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+func (a *Auditor) Write(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+func (a *Auditor) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+func Shutdown() error {
+	if globalAuditor != nil {
+		return globalAuditor.Close()
+	}
+	return nil
+}
+
+// Call accumulates what a single tool invocation did until End writes it out.
+type Call struct {
+	auditor *Auditor
+	entry   Entry
+	start   time.Time
+
+	mu    sync.Mutex
+	files []FileChange
+	seen  map[string]bool
+}
+
+type callKey struct{}
+
+// Begin starts auditing a tool call. It returns ctx unchanged and a nil Call
+// when auditing is disabled; all Call methods accept a nil receiver.
+func Begin(ctx context.Context, sessionID, callID, tool string, args any) (context.Context, *Call) {
+	auditor := globalAuditor
+	if auditor == nil {
+		return ctx, nil
+	}
+
+	call := &Call{
+		auditor: auditor,
+		start:   time.Now(),
+		seen:    make(map[string]bool),
+		entry: Entry{
+			SessionID: sessionID,
+			CallID:    callID,
+			Tool:      tool,
+			Arguments: auditor.redactor.RedactJSON(args),
+		},
+	}
+	return context.WithValue(ctx, callKey{}, call), call
+}
+
+// TrackFiles records the current hash of paths that the running tool is about
+// to modify. It must be called before the first write to each path.
+func TrackFiles(ctx context.Context, paths ...string) {
+	call, _ := ctx.Value(callKey{}).(*Call)
+	if call == nil {
+		return
+	}
+
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	for _, path := range paths {
+		if call.seen[path] {
+			continue
+		}
+		call.seen[path] = true
+		call.files = append(call.files, FileChange{Path: path, Before: HashPath(path)})
+	}
+}
+
+func (c *Call) End(result *mcp.CallToolResult, output any, err error) {
+	if c == nil {
+		return
+	}
+
+	entry := c.entry
+	entry.Timestamp = c.start.UTC()
+	entry.DurationMS = float64(time.Since(c.start).Microseconds()) / 1000
+	entry.Status = StatusOK
+
+	if err != nil {
+		entry.Status = StatusError
+		entry.Error = err.Error()
+	} else {
+		entry.ResultBytes = resultSize(result, output)
+		if c.auditor.recordOutput {
+			entry.Output = c.auditor.redactor.RedactJSON(output)
+		}
+		if result != nil && result.IsError {
+			entry.Status = StatusError
+		} else if status, ok := output.(ExitStatus); ok {
+			code := status.ExitStatus()
+			entry.ExitCode = &code
+		}
+	}
+
+	c.mu.Lock()
+	for _, file := range c.files {
+		file.After = HashPath(file.Path)
+		entry.Files = append(entry.Files, file)
+	}
+	c.mu.Unlock()
+
+	if err := c.auditor.Write(entry); err != nil {
+		logger.Error("Failed to write audit entry", err, "tool", entry.Tool, "call_id", entry.CallID)
+	}
+}
+
+func resultSize(result *mcp.CallToolResult, output any) int {
+	size := 0
+	if data, err := json.Marshal(output); err == nil {
+		size += len(data)
+	}
+	if result != nil {
+		for _, content := range result.Content {
+			switch c := content.(type) {
+			case *mcp.TextContent:
+				size += len(c.Text)
+			case *mcp.ImageContent:
+				size += len(c.Data)
+			}
+		}
+	}
+	return size
+}
+
+// HashPath returns the sha256 of the file at path, DirectoryHash for a
+// directory, or "" if it does not exist.
+func HashPath(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return DirectoryHash
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
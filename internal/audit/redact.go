@@ -0,0 +1,115 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const Redacted = "[REDACTED]"
+
+var (
+	DefaultRedactEnvKeys = []string{
+		`(?i)(secret|token|passw(or)?d|api[_-]?key|access[_-]?key|private[_-]?key|credential|auth)`,
+	}
+	DefaultRedactPatterns = []string{
+		`(?i)bearer\s+[a-z0-9._~+/=-]{8,}`,
+		`gh[pousr]_[A-Za-z0-9]{20,}`,
+		`github_pat_[A-Za-z0-9_]{20,}`,
+		`AKIA[0-9A-Z]{16}`,
+		`xox[abprs]-[A-Za-z0-9-]{10,}`,
+		`sk-[A-Za-z0-9_-]{20,}`,
+		`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	}
+)
+
+// Redactor masks secrets in tool arguments before they reach the audit log.
+// Values of "env" entries whose names match an env key pattern are replaced
+// outright; every other string has matches of the value patterns masked.
+type Redactor struct {
+	envKeys  []*regexp.Regexp
+	patterns []*regexp.Regexp
+}
+
+func NewRedactor(envKeys, patterns []string) (*Redactor, error) {
+	if envKeys == nil {
+		envKeys = DefaultRedactEnvKeys
+	}
+	if patterns == nil {
+		patterns = DefaultRedactPatterns
+	}
+
+	r := &Redactor{}
+	for _, pattern := range envKeys {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact_env_keys pattern %q: %w", pattern, err)
+		}
+		r.envKeys = append(r.envKeys, re)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact_patterns pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *Redactor) RedactJSON(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage(`null`)
+	}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return json.RawMessage(`null`)
+	}
+
+	redacted, err := json.Marshal(r.redactValue("", value))
+	if err != nil {
+		return json.RawMessage(`null`)
+	}
+	return redacted
+}
+
+func (r *Redactor) RedactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+func (r *Redactor) redactValue(key string, value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if key == "env" && r.isSecretEnvKey(k) {
+				v[k] = Redacted
+				continue
+			}
+			v[k] = r.redactValue(k, item)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = r.redactValue(key, item)
+		}
+		return v
+	case string:
+		return r.RedactString(v)
+	default:
+		return v
+	}
+}
+
+func (r *Redactor) isSecretEnvKey(name string) bool {
+	for _, re := range r.envKeys {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -8,13 +8,104 @@ import (
 
 type LoggingConfig struct {
 	Level      string `json:"level"`
+	Format     string `json:"format"`
 	OutputFile string `json:"output_file"`
 	MaxSizeMB  int64  `json:"max_size_mb"`
 	Console    bool   `json:"console"`
+	RotateAt   string `json:"rotate_at"`
+	MaxBackups int    `json:"max_backups"`
+	MaxAgeDays int    `json:"max_age_days"`
+	Compress   bool   `json:"compress"`
+}
+
+type WorkspaceConfig struct {
+	Roots []string `json:"roots"`
+}
+
+type ReadConfig struct {
+	ImageMaxDimension int `json:"image_max_dimension"`
+}
+
+type AuditConfig struct {
+	OutputFile     string   `json:"output_file"`
+	RedactEnvKeys  []string `json:"redact_env_keys"`
+	RedactPatterns []string `json:"redact_patterns"`
+	RecordOutput   bool     `json:"record_output"`
+}
+
+type ToolsConfig struct {
+	Profile  string   `json:"profile"`
+	Enabled  []string `json:"enabled"`
+	Disabled []string `json:"disabled"`
+}
+
+type RunPolicyRule struct {
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Action  string   `json:"action"`
+	Reason  string   `json:"reason"`
+}
+
+type RunPolicyConfig struct {
+	Default string          `json:"default"`
+	Rules   []RunPolicyRule `json:"rules"`
+}
+
+type RunLimitsConfig struct {
+	CPUSeconds     int `json:"cpu_seconds"`
+	AddressSpaceMB int `json:"address_space_mb"`
+	OpenFiles      int `json:"open_files"`
+	// Processes is RLIMIT_NPROC, which counts all processes of the user,
+	// not just those the command starts.
+	Processes int `json:"processes"`
+}
+
+type RunSandboxConfig struct {
+	// Enabled makes every mount read-only except the workspace roots and
+	// Writable. That includes /tmp and the Go build cache (GOCACHE), so
+	// `go build` and `go test` fail in the sandbox unless they are listed.
+	Enabled bool `json:"enabled"`
+	// Network keeps the host network; by default sandboxed commands only get
+	// a private loopback interface.
+	Network bool `json:"network"`
+	// Writable lists paths besides the workspace roots that stay writable.
+	Writable []string `json:"writable"`
+}
+
+type RunEnvOverrideConfig struct {
+	Command string            `json:"command"`
+	Set     map[string]string `json:"set"`
+	Unset   []string          `json:"unset"`
+}
+
+type RunEnvPolicyConfig struct {
+	Mode     string                 `json:"mode"`
+	Allow    []string               `json:"allow"`
+	Unset    []string               `json:"unset"`
+	Set      map[string]string      `json:"set"`
+	Commands []RunEnvOverrideConfig `json:"commands"`
+}
+
+type RunConfig struct {
+	Policy RunPolicyConfig `json:"policy"`
+	Shell  string          `json:"shell"`
+	// MaxProcesses caps concurrently running background processes.
+	MaxProcesses int `json:"max_processes"`
+	// MaxOutputBytes caps each of stdout and stderr in a run result.
+	MaxOutputBytes int                `json:"max_output_bytes"`
+	Limits         RunLimitsConfig    `json:"limits"`
+	Sandbox        RunSandboxConfig   `json:"sandbox"`
+	EnvPolicy      RunEnvPolicyConfig `json:"env_policy"`
 }
 
 type Config struct {
-	Logging LoggingConfig `json:"logging"`
+	Logging   LoggingConfig   `json:"logging"`
+	Workspace WorkspaceConfig `json:"workspace"`
+	Read      ReadConfig      `json:"read"`
+	Audit     AuditConfig     `json:"audit"`
+	Tools     ToolsConfig     `json:"tools"`
+	Run       RunConfig       `json:"run"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
@@ -40,6 +131,9 @@ func loadConfigFromFile(path string) (*Config, error) {
 	if config.Logging.Level == "" {
 		config.Logging.Level = "INFO"
 	}
+	if config.Logging.Format == "" {
+		config.Logging.Format = "text"
+	}
 	if config.Logging.OutputFile == "" {
 		config.Logging.OutputFile = "code-tools-mcp.log"
 	}
@@ -47,9 +141,5 @@ func loadConfigFromFile(path string) (*Config, error) {
 		config.Logging.MaxSizeMB = 10
 	}
 
-	if config.Logging.OutputFile != "" {
-		config.Logging.Console = true
-	}
-
 	return &config, nil
 }
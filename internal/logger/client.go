@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const clientLoggerName = "CodeToolsMcp"
+
+// clientHandler sends log records to connected sessions as
+// notifications/message. Sessions only receive records once they have
+// called logging/setLevel, and only at or above that level. Records logged
+// with a session-scoped context go to that session alone.
+type clientHandler struct {
+	target *clientTarget
+	wraps  []func(slog.Handler) slog.Handler
+}
+
+type clientTarget struct {
+	mu     sync.RWMutex
+	server *mcp.Server
+}
+
+func newClientHandler() *clientHandler {
+	return &clientHandler{target: &clientTarget{}}
+}
+
+func (l *Logger) ForwardToClients(server *mcp.Server) {
+	l.clients.target.mu.Lock()
+	l.clients.target.server = server
+	l.clients.target.mu.Unlock()
+}
+
+func ForwardToClients(server *mcp.Server) {
+	if globalLogger != nil {
+		globalLogger.ForwardToClients(server)
+	}
+}
+
+func (h *clientHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	h.target.mu.RLock()
+	defer h.target.mu.RUnlock()
+	return h.target.server != nil
+}
+
+func (h *clientHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.target.mu.RLock()
+	server := h.target.server
+	h.target.mu.RUnlock()
+	if server == nil {
+		return nil
+	}
+
+	sessions := server.Sessions()
+	if session := sessionFrom(ctx); session != nil {
+		sessions = func(yield func(*mcp.ServerSession) bool) { yield(session) }
+	}
+
+	for session := range sessions {
+		var handler slog.Handler = mcp.NewLoggingHandler(session, &mcp.LoggingHandlerOptions{LoggerName: clientLoggerName})
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		for _, wrap := range h.wraps {
+			handler = wrap(handler)
+		}
+		// Delivery is best effort: a session that is closing must not fail
+		// the local log write.
+		_ = handler.Handle(ctx, r.Clone())
+	}
+	return nil
+}
+
+func (h *clientHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *clientHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
+func (h *clientHandler) with(wrap func(slog.Handler) slog.Handler) *clientHandler {
+	wraps := append(append([]func(slog.Handler) slog.Handler(nil), h.wraps...), wrap)
+	return &clientHandler{target: h.target, wraps: wraps}
+}
@@ -1,74 +1,71 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
 )
 
-type LogLevel int
-
 const (
-	DEBUG LogLevel = iota
-	INFO
-	WARN
-	ERROR
+	FormatText = "text"
+	FormatJSON = "json"
 )
 
-var levelNames = map[LogLevel]string{
-	DEBUG: "DEBUG",
-	INFO:  "INFO",
-	WARN:  "WARN",
-	ERROR: "ERROR",
-}
-
 type Logger struct {
-	slogger  *slog.Logger
-	logLevel LogLevel
-	logFile  *os.File
-}
-
-func ParseLogLevel(level string) LogLevel {
-	switch level {
-	case "DEBUG", "debug":
-		return DEBUG
-	case "INFO", "info":
-		return INFO
-	case "WARN", "warn", "WARNING", "warning":
-		return WARN
-	case "ERROR", "error":
-		return ERROR
+	slogger *slog.Logger
+	clients *clientHandler
+	logFile *RotatingFile
+}
+
+func ParseLogLevel(level string) slog.Level {
+	switch strings.ToUpper(level) {
+	case "DEBUG":
+		return slog.LevelDebug
+	case "INFO":
+		return slog.LevelInfo
+	case "WARN", "WARNING":
+		return slog.LevelWarn
+	case "ERROR":
+		return slog.LevelError
 	default:
-		return INFO
+		return slog.LevelInfo
 	}
 }
 
-func LogLevelString(level LogLevel) string {
-	if name, exists := levelNames[level]; exists {
-		return name
-	}
-	return "INFO"
+func LogLevelString(level slog.Level) string {
+	return level.String()
 }
 
 func ConfigFromLoggingConfig(logCfg config.LoggingConfig) Config {
 	return Config{
 		Level:      ParseLogLevel(logCfg.Level),
+		Format:     logCfg.Format,
 		OutputFile: logCfg.OutputFile,
 		MaxSize:    logCfg.MaxSizeMB,
 		Console:    logCfg.Console,
+		RotateAt:   logCfg.RotateAt,
+		MaxBackups: logCfg.MaxBackups,
+		MaxAgeDays: logCfg.MaxAgeDays,
+		Compress:   logCfg.Compress,
 	}
 }
 
 type Config struct {
-	Level      LogLevel
+	Level      slog.Level
+	Format     string
 	OutputFile string
 	MaxSize    int64
 	Console    bool
+	RotateAt   string
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
 }
 
 var globalLogger *Logger
@@ -83,71 +80,58 @@ func Initialize(cfg Config) error {
 }
 
 func NewLogger(cfg Config) (*Logger, error) {
-	logger := &Logger{
-		logLevel: cfg.Level,
+	format := strings.ToLower(cfg.Format)
+	if format == "" {
+		format = FormatText
 	}
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("unsupported log format: %s (expected text or json)", cfg.Format)
+	}
+
+	logger := &Logger{}
 
 	var writers []io.Writer
 
 	if cfg.Console {
-		writers = append(writers, os.Stdout)
+		writers = append(writers, os.Stderr)
 	}
 
 	if cfg.OutputFile != "" {
-
-		dir := filepath.Dir(cfg.OutputFile)
-		if dir != "." && dir != "" {
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return nil, fmt.Errorf("failed to create log directory: %w", err)
-			}
-		}
-
-		if err := rotateLogIfNeeded(cfg.OutputFile, cfg.MaxSize*1024*1024); err != nil {
-			return nil, fmt.Errorf("failed to rotate log: %w", err)
-		}
-
-		file, err := os.OpenFile(cfg.OutputFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		file, err := OpenRotatingFile(cfg.OutputFile, RotationConfig{
+			MaxSize:    cfg.MaxSize * 1024 * 1024,
+			RotateAt:   cfg.RotateAt,
+			MaxBackups: cfg.MaxBackups,
+			MaxAgeDays: cfg.MaxAgeDays,
+			Compress:   cfg.Compress,
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %w", err)
+			return nil, err
 		}
 		logger.logFile = file
 		writers = append(writers, file)
 	}
 
-	var writer io.Writer
-	if len(writers) == 1 {
-		writer = writers[0]
-	} else {
-		writer = io.MultiWriter(writers...)
-	}
-
-	opts := &slog.HandlerOptions{
-		Level: slog.Level(-4),
-	}
-	handler := slog.NewTextHandler(writer, opts)
-	logger.slogger = slog.New(handler)
-
-	return logger, nil
-}
-
-func rotateLogIfNeeded(filename string, maxSize int64) error {
-	info, err := os.Stat(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
+	var handlers []slog.Handler
+	if len(writers) > 0 {
+		writer := writers[0]
+		if len(writers) > 1 {
+			writer = io.MultiWriter(writers...)
 		}
-		return err
-	}
 
-	if info.Size() >= maxSize {
-		timestamp := time.Now().Format("20060102-150405")
-		backupName := fmt.Sprintf("%s.%s", filename, timestamp)
-		if err := os.Rename(filename, backupName); err != nil {
-			return fmt.Errorf("failed to rotate log file: %w", err)
+		opts := &slog.HandlerOptions{Level: cfg.Level}
+		if format == FormatJSON {
+			handlers = append(handlers, slog.NewJSONHandler(writer, opts))
+		} else {
+			handlers = append(handlers, slog.NewTextHandler(writer, opts))
 		}
 	}
 
-	return nil
+	logger.clients = newClientHandler()
+	handlers = append(handlers, logger.clients)
+
+	logger.slogger = slog.New(&contextHandler{next: &fanoutHandler{handlers: handlers}})
+
+	return logger, nil
 }
 
 func (l *Logger) Close() error {
@@ -157,117 +141,108 @@ func (l *Logger) Close() error {
 	return nil
 }
 
-func (l *Logger) shouldLog(level LogLevel) bool {
-	return level >= l.logLevel
+func (l *Logger) Slog() *slog.Logger {
+	return l.slogger
 }
 
-func (l *Logger) log(level LogLevel, msg string, fields map[string]interface{}) {
-	if !l.shouldLog(level) {
-		return
-	}
+func (l *Logger) Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	l.slogger.Log(ctx, level, msg, args...)
+}
 
-	timestamp := time.Now().UTC().Format("2006-01-02 15:04:05")
-	logLine := fmt.Sprintf("[%s] %s: %s", timestamp, levelNames[level], msg)
+func (l *Logger) Debug(ctx context.Context, msg string, args ...any) {
+	l.slogger.Log(ctx, slog.LevelDebug, msg, args...)
+}
 
-	for k, v := range fields {
-		logLine += fmt.Sprintf(" %s=%v", k, v)
-	}
+func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
+	l.slogger.Log(ctx, slog.LevelInfo, msg, args...)
+}
 
-	l.slogger.Info(logLine)
+func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
+	l.slogger.Log(ctx, slog.LevelWarn, msg, args...)
 }
 
-func (l *Logger) Debug(msg string, fields ...map[string]interface{}) {
-	fieldMap := make(map[string]interface{})
-	if len(fields) > 0 {
-		fieldMap = fields[0]
+func (l *Logger) Error(ctx context.Context, msg string, err error, args ...any) {
+	if err != nil {
+		args = append(args, slog.String("error", err.Error()))
 	}
-	l.log(DEBUG, msg, fieldMap)
+	l.slogger.Log(ctx, slog.LevelError, msg, args...)
 }
 
-func (l *Logger) Info(msg string, fields ...map[string]interface{}) {
-	fieldMap := make(map[string]interface{})
-	if len(fields) > 0 {
-		fieldMap = fields[0]
-	}
-	l.log(INFO, msg, fieldMap)
+func Debug(msg string, args ...any) {
+	DebugContext(context.Background(), msg, args...)
 }
 
-func (l *Logger) Warn(msg string, fields ...map[string]interface{}) {
-	fieldMap := make(map[string]interface{})
-	if len(fields) > 0 {
-		fieldMap = fields[0]
-	}
-	l.log(WARN, msg, fieldMap)
+func Info(msg string, args ...any) {
+	InfoContext(context.Background(), msg, args...)
 }
 
-func (l *Logger) Error(msg string, err error, fields ...map[string]interface{}) {
-	fieldMap := make(map[string]interface{})
-	if len(fields) > 0 {
-		fieldMap = fields[0]
-	}
-	if err != nil {
-		fieldMap["error"] = err.Error()
-	}
-	l.log(ERROR, msg, fieldMap)
+func Warn(msg string, args ...any) {
+	WarnContext(context.Background(), msg, args...)
 }
 
-func Debug(msg string, fields ...map[string]interface{}) {
+func Error(msg string, err error, args ...any) {
+	ErrorContext(context.Background(), msg, err, args...)
+}
+
+func DebugContext(ctx context.Context, msg string, args ...any) {
 	if globalLogger != nil {
-		globalLogger.Debug(msg, fields...)
+		globalLogger.Debug(ctx, msg, args...)
 	}
 }
 
-func Info(msg string, fields ...map[string]interface{}) {
+func InfoContext(ctx context.Context, msg string, args ...any) {
 	if globalLogger != nil {
-		globalLogger.Info(msg, fields...)
+		globalLogger.Info(ctx, msg, args...)
 	}
 }
 
-func Warn(msg string, fields ...map[string]interface{}) {
+func WarnContext(ctx context.Context, msg string, args ...any) {
 	if globalLogger != nil {
-		globalLogger.Warn(msg, fields...)
+		globalLogger.Warn(ctx, msg, args...)
 	}
 }
 
-func Error(msg string, err error, fields ...map[string]interface{}) {
+func ErrorContext(ctx context.Context, msg string, err error, args ...any) {
 	if globalLogger != nil {
-		globalLogger.Error(msg, err, fields...)
+		globalLogger.Error(ctx, msg, err, args...)
 	}
 }
 
-func LogToolCall(toolName string, params interface{}, result interface{}, err error) {
+// LogOperation records the outcome of a unit of work started at start, at
+// INFO on success and ERROR on failure, with its duration attached.
+func LogOperation(ctx context.Context, operation string, start time.Time, err error, args ...any) {
+	args = append(args, slog.String("operation", operation), durationAttr(start))
 	if err != nil {
-		Error(fmt.Sprintf("Tool call failed: %s", toolName), err)
+		ErrorContext(ctx, "Operation failed", err, args...)
 	} else {
-		Info(fmt.Sprintf("Tool call completed: %s", toolName))
+		InfoContext(ctx, "Operation completed", args...)
 	}
 }
 
-func LogDatabaseOperation(operation, query string, rowsAffected int64, err error) {
-	sanitizedQuery := query
-	if len(sanitizedQuery) > 100 {
-		sanitizedQuery = sanitizedQuery[:100] + "..."
-	}
-
+// LogEvent records a point-in-time lifecycle event such as a session opening.
+func LogEvent(ctx context.Context, event string, err error, args ...any) {
 	if err != nil {
-		Error(fmt.Sprintf("%s operation failed: %s", operation, sanitizedQuery), err)
+		ErrorContext(ctx, event, err, args...)
 	} else {
-		if rowsAffected > 0 {
-			Info(fmt.Sprintf("%s operation completed: %s (%d rows affected)", operation, sanitizedQuery, rowsAffected))
-		} else {
-			Info(fmt.Sprintf("%s operation completed: %s", operation, sanitizedQuery))
-		}
+		InfoContext(ctx, event, args...)
 	}
 }
 
-func LogConnectionEvent(event, connectionName, dbType string, err error) {
+// LogToolCall expects the tool name and call id to already be on ctx (see
+// WithToolCall).
+func LogToolCall(ctx context.Context, start time.Time, err error) {
+	duration := durationAttr(start)
 	if err != nil {
-		Error(fmt.Sprintf("Connection event failed: %s to %s (%s)", event, connectionName, dbType), err)
+		ErrorContext(ctx, "Tool call failed", err, duration)
 	} else {
-		Info(fmt.Sprintf("Connection event completed: %s to %s (%s)", event, connectionName, dbType))
+		InfoContext(ctx, "Tool call completed", duration)
 	}
 }
 
+func durationAttr(start time.Time) slog.Attr {
+	return slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000)
+}
+
 func GetGlobalLogger() *Logger {
 	return globalLogger
 }
@@ -0,0 +1,328 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102-150405"
+
+type RotationConfig struct {
+	MaxSize    int64
+	RotateAt   string
+	MaxBackups int
+	MaxAgeDays int
+	Compress   bool
+}
+
+// RotatingFile is an append-only log file that rotates itself when it grows
+// past MaxSize or when the daily RotateAt time passes. Rotated files are
+// renamed to <name>.<timestamp>, optionally gzipped, and pruned by count and
+// age in the background.
+type RotatingFile struct {
+	path string
+	cfg  RotationConfig
+
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	dailyHour  int
+	dailyMin   int
+	daily      bool
+	lastStamp  string
+	lastSeq    int
+
+	mill sync.Mutex
+	wg   sync.WaitGroup
+}
+
+func OpenRotatingFile(path string, cfg RotationConfig) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, cfg: cfg}
+
+	if cfg.RotateAt != "" {
+		at, err := time.Parse("15:04", cfg.RotateAt)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rotate_at %q (expected HH:MM): %w", cfg.RotateAt, err)
+		}
+		r.daily = true
+		r.dailyHour, r.dailyMin = at.Hour(), at.Minute()
+	}
+
+	dir := filepath.Dir(path)
+	if dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	if r.dueAtOpen() {
+		if err := r.rotate(); err != nil {
+			r.file.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// dueAtOpen reports whether the existing file should be rotated before use:
+// it is already oversized, or it was last written before the most recent
+// daily rotation time (the server was not running when it passed).
+func (r *RotatingFile) dueAtOpen() bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.cfg.MaxSize > 0 && r.size >= r.cfg.MaxSize {
+		return true
+	}
+	if r.daily {
+		info, err := r.file.Stat()
+		return err == nil && info.ModTime().Before(r.nextRotate.AddDate(0, 0, -1))
+	}
+	return false
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	dueBySize := r.cfg.MaxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.cfg.MaxSize
+	dueByTime := r.daily && !time.Now().Before(r.nextRotate)
+	if dueBySize || dueByTime {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate forces a rotation regardless of size or schedule.
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	if r.daily {
+		r.nextRotate = nextDaily(time.Now(), r.dailyHour, r.dailyMin)
+	}
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+
+	backup := r.backupName(time.Now())
+	if err := os.Rename(r.path, backup); err != nil && !os.IsNotExist(err) {
+		// Keep logging to the current file rather than losing records.
+		if openErr := r.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.millBackups(backup)
+	}()
+	return nil
+}
+
+// backupName never reuses a sequence number within the same second, even
+// after pruning, so that newer backups always sort after older ones.
+func (r *RotatingFile) backupName(now time.Time) string {
+	stamp := now.Format(backupTimeFormat)
+	seq := 0
+	if stamp == r.lastStamp {
+		seq = r.lastSeq + 1
+	}
+
+	for ; ; seq++ {
+		candidate := fmt.Sprintf("%s.%s", r.path, stamp)
+		if seq > 0 {
+			candidate = fmt.Sprintf("%s-%d", candidate, seq)
+		}
+		_, errPlain := os.Lstat(candidate)
+		_, errGz := os.Lstat(candidate + ".gz")
+		if os.IsNotExist(errPlain) && os.IsNotExist(errGz) {
+			r.lastStamp, r.lastSeq = stamp, seq
+			return candidate
+		}
+	}
+}
+
+func (r *RotatingFile) millBackups(latest string) {
+	r.mill.Lock()
+	defer r.mill.Unlock()
+
+	if r.cfg.Compress {
+		if err := compressFile(latest); err != nil {
+			Error("Failed to compress rotated log", err, "path", latest)
+		}
+	}
+
+	backups, err := r.listBackups()
+	if err != nil {
+		Error("Failed to list rotated logs", err, "path", r.path)
+		return
+	}
+
+	cutoff := time.Time{}
+	if r.cfg.MaxAgeDays > 0 {
+		cutoff = time.Now().Add(-time.Duration(r.cfg.MaxAgeDays) * 24 * time.Hour)
+	}
+
+	for i, backup := range backups {
+		expired := !cutoff.IsZero() && backup.rotatedAt.Before(cutoff)
+		excess := r.cfg.MaxBackups > 0 && i >= r.cfg.MaxBackups
+		if expired || excess {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				Error("Failed to remove rotated log", err, "path", backup.path)
+			}
+		}
+	}
+}
+
+type logBackup struct {
+	path      string
+	rotatedAt time.Time
+	seq       int
+}
+
+// listBackups returns rotated files for this log, newest first.
+func (r *RotatingFile) listBackups() ([]logBackup, error) {
+	prefix := filepath.Base(r.path) + "."
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []logBackup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".gz")
+		if len(stamp) < len(backupTimeFormat) {
+			continue
+		}
+		rotatedAt, err := time.ParseInLocation(backupTimeFormat, stamp[:len(backupTimeFormat)], time.Local)
+		if err != nil {
+			continue
+		}
+		seq := 0
+		if rest := stamp[len(backupTimeFormat):]; rest != "" {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(rest, "-")); err != nil || !strings.HasPrefix(rest, "-") {
+				continue
+			}
+		}
+		backups = append(backups, logBackup{path: filepath.Join(filepath.Dir(r.path), name), rotatedAt: rotatedAt, seq: seq})
+	}
+
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].rotatedAt.Equal(backups[j].rotatedAt) {
+			return backups[i].seq > backups[j].seq
+		}
+		return backups[i].rotatedAt.After(backups[j].rotatedAt)
+	})
+	return backups, nil
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		gz.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+func nextDaily(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
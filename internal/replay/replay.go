@@ -0,0 +1,273 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/server"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const maxDiffValue = 200
+
+type Options struct {
+	// Target is the directory the session is replayed in.
+	Target string
+	// SourceRoot is the directory the session was recorded in. Occurrences
+	// in arguments and recorded outputs are rewritten to Target.
+	SourceRoot string
+	// Session limits the replay to entries with this session id.
+	Session string
+	Out     io.Writer
+}
+
+type Summary struct {
+	Calls    int
+	Matched  int
+	Diffs    int
+	Redacted int
+	// Unverified counts successful calls recorded without their output,
+	// whose outputs could not be compared.
+	Unverified int
+}
+
+func Run(ctx context.Context, client *server.InProcessClient, entries []audit.Entry, opts Options) (Summary, error) {
+	var summary Summary
+
+	rewrite := func(s string) string { return s }
+	if opts.SourceRoot != "" && opts.Target != "" {
+		source := filepath.Clean(opts.SourceRoot)
+		target := filepath.Clean(opts.Target)
+		rewrite = func(s string) string { return strings.ReplaceAll(s, source, target) }
+	}
+
+	// Each recorded session is replayed in a session of its own, so state
+	// such as the shell's directory and files read does not leak between them.
+	sessions := make(map[string]*server.InProcessSession)
+	defer func() {
+		for _, session := range sessions {
+			session.Close()
+		}
+	}()
+
+	for _, entry := range entries {
+		if opts.Session != "" && entry.SessionID != opts.Session {
+			continue
+		}
+		summary.Calls++
+
+		session, ok := sessions[entry.SessionID]
+		if !ok {
+			var err error
+			if session, err = client.NewSession(ctx); err != nil {
+				return summary, err
+			}
+			sessions[entry.SessionID] = session
+		}
+
+		args, err := rewriteJSON(entry.Arguments, rewrite)
+		if err != nil {
+			return summary, fmt.Errorf("call %s: invalid recorded arguments: %w", entry.CallID, err)
+		}
+		if strings.Contains(string(args), audit.Redacted) {
+			summary.Redacted++
+		}
+
+		result, err := session.Call(ctx, entry.Tool, args)
+		if err != nil {
+			return summary, fmt.Errorf("call %s (%s): %w", entry.CallID, entry.Tool, err)
+		}
+
+		diffs := compare(entry, result, rewrite)
+		if len(diffs) == 0 {
+			summary.Matched++
+			if len(entry.Output) == 0 && entry.Status == audit.StatusOK {
+				summary.Unverified++
+				fmt.Fprintf(opts.Out, "[%d] %s %s: ok (output not recorded, not compared)\n", summary.Calls, entry.Tool, entry.CallID)
+				continue
+			}
+			fmt.Fprintf(opts.Out, "[%d] %s %s: ok\n", summary.Calls, entry.Tool, entry.CallID)
+			continue
+		}
+
+		summary.Diffs++
+		fmt.Fprintf(opts.Out, "[%d] %s %s: DIFF\n", summary.Calls, entry.Tool, entry.CallID)
+		for _, diff := range diffs {
+			fmt.Fprintf(opts.Out, "    %s\n", diff)
+		}
+	}
+
+	return summary, nil
+}
+
+func compare(entry audit.Entry, result *mcp.CallToolResult, rewrite func(string) string) []string {
+	var diffs []string
+
+	status := audit.StatusOK
+	if result.IsError {
+		status = audit.StatusError
+	}
+	if status != entry.Status {
+		diff := fmt.Sprintf("status: recorded %s, replayed %s", entry.Status, status)
+		if result.IsError {
+			diff += fmt.Sprintf(" (%s)", truncate(resultText(result)))
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if entry.Error != "" {
+		replayedError := resultText(result)
+		if rewrite(entry.Error) != replayedError {
+			diffs = append(diffs, fmt.Sprintf("error: recorded %q, replayed %q", truncate(rewrite(entry.Error)), truncate(replayedError)))
+		}
+	}
+
+	replayed := normalize(result.StructuredContent)
+
+	if entry.ExitCode != nil && status == audit.StatusOK {
+		var exitCode any
+		if fields, ok := replayed.(map[string]any); ok {
+			exitCode = fields["exit_code"]
+		}
+		if code, ok := exitCode.(float64); !ok || int(code) != *entry.ExitCode {
+			diffs = append(diffs, fmt.Sprintf("exit_code: recorded %d, replayed %v", *entry.ExitCode, exitCode))
+		}
+	}
+
+	if len(entry.Output) > 0 && status == audit.StatusOK {
+		recordedOutput, err := rewriteJSON(entry.Output, rewrite)
+		if err == nil {
+			var recorded any
+			if err := json.Unmarshal(recordedOutput, &recorded); err == nil {
+				diffs = append(diffs, diffValues("output", recorded, replayed)...)
+			}
+		}
+	}
+
+	for _, file := range entry.Files {
+		path := rewrite(file.Path)
+		if after := audit.HashPath(path); after != file.After {
+			diffs = append(diffs, fmt.Sprintf("file %s: recorded %s, replayed %s", path, hashLabel(file.After), hashLabel(after)))
+		}
+	}
+
+	return diffs
+}
+
+func diffValues(path string, recorded, replayed any) []string {
+	switch r := recorded.(type) {
+	case map[string]any:
+		p, ok := replayed.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range r {
+			keys[k] = true
+		}
+		for k := range p {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, k := range sorted {
+			diffs = append(diffs, diffValues(path+"."+k, r[k], p[k])...)
+		}
+		return diffs
+	case []any:
+		p, ok := replayed.([]any)
+		if !ok || len(p) != len(r) {
+			break
+		}
+		var diffs []string
+		for i := range r {
+			diffs = append(diffs, diffValues(fmt.Sprintf("%s[%d]", path, i), r[i], p[i])...)
+		}
+		return diffs
+	}
+
+	recordedJSON, _ := json.Marshal(recorded)
+	replayedJSON, _ := json.Marshal(replayed)
+	if string(recordedJSON) == string(replayedJSON) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: recorded %s, replayed %s", path, truncate(string(recordedJSON)), truncate(string(replayedJSON)))}
+}
+
+func rewriteJSON(data json.RawMessage, rewrite func(string) string) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(rewriteValue(value, rewrite))
+}
+
+func rewriteValue(value any, rewrite func(string) string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = rewriteValue(item, rewrite)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = rewriteValue(item, rewrite)
+		}
+		return v
+	case string:
+		return rewrite(v)
+	default:
+		return v
+	}
+}
+
+// normalize round-trips v through JSON so it compares like a recorded value.
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func hashLabel(hash string) string {
+	if hash == "" {
+		return "(missing)"
+	}
+	return hash
+}
+
+func truncate(s string) string {
+	if len(s) <= maxDiffValue {
+		return s
+	}
+	return s[:maxDiffValue] + "..."
+}
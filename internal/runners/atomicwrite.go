@@ -0,0 +1,85 @@
+package runners
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces path with data without ever exposing a partially
+// written file: the data goes to a temporary file in the same directory, is
+// synced, and then renamed over the target. When the target already exists
+// its mode and, where permitted, its ownership are kept; otherwise perm is used.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	return writeAtomic(path, bytes.NewReader(data), perm, true)
+}
+
+// fileMode returns the permission bits of info together with the setuid,
+// setgid and sticky bits, which Perm drops.
+func fileMode(info fs.FileInfo) fs.FileMode {
+	return info.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+func writeAtomic(path string, r io.Reader, perm fs.FileMode, keepMode bool) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	mode := perm
+	var existing fs.FileInfo
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("path is a directory: %s", path)
+		}
+		existing = info
+		if keepMode {
+			mode = fileMode(info)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	committed := false
+	defer func() {
+		if !committed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	// Changing the owner clears the setuid and setgid bits, so the mode goes
+	// on last.
+	if existing != nil {
+		preserveOwner(tmp, existing)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	committed = true
+
+	syncDir(dir)
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package runners
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+func preserveOwner(f *os.File, info fs.FileInfo) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	_ = f.Chown(int(stat.Uid), int(stat.Gid))
+}
+
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package runners
+
+import (
+	"io/fs"
+	"os"
+)
+
+func preserveOwner(f *os.File, info fs.FileInfo) {}
+
+func syncDir(dir string) {}
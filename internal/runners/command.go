@@ -1,10 +1,9 @@
 package runners
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -16,13 +15,26 @@ type RunCommandInput struct {
 	WorkingDir     string
 	TimeoutSeconds int
 	Stdin          string
-	Env            map[string]string
+	// Env is the complete environment; nil inherits the server's.
+	Env []string
+	// MaxOutputBytes caps each captured stream; the full output of a
+	// truncated stream goes to Spool when it is set.
+	MaxOutputBytes int
+	Spool          *OutputSpool
+	Isolation      *Isolation
+	OnOutput       OutputFunc
+	// Pty runs the command on a pseudo-terminal; StripANSI removes terminal
+	// escape sequences from the captured output.
+	Pty       *PtyOptions
+	StripANSI bool
 }
 
 type RunCommandResult struct {
-	Stdout   string
-	Stderr   string
-	ExitCode int
+	Stdout     string
+	Stderr     string
+	ExitCode   int
+	WorkingDir string
+	Truncated  *OutputTruncation
 }
 
 func RunCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, error) {
@@ -43,28 +55,41 @@ func RunCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 		cmd.Dir = input.WorkingDir
 	}
 
-	if len(input.Env) > 0 {
-		env := os.Environ()
-		for key, value := range input.Env {
-			env = append(env, fmt.Sprintf("%s=%s", key, value))
-		}
-		cmd.Env = env
-	}
+	cmd.Env = input.Env
 
-	if input.Stdin != "" {
-		cmd.Stdin = strings.NewReader(input.Stdin)
+	if err := input.Isolation.prepareCmd(cmd); err != nil {
+		return RunCommandResult{}, err
 	}
 
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
+	capture := newOutputCapture(input.MaxOutputBytes, input.Spool, input.OnOutput)
+	return runCmd(cmd, input.Stdin, capture, input.Pty, input.StripANSI)
+}
 
-	err := cmd.Run()
+func runCmd(cmd *exec.Cmd, stdin string, capture *outputCapture, pty *PtyOptions, stripANSI bool) (RunCommandResult, error) {
+	var err error
+	if pty != nil {
+		err = runPtyCmd(cmd, stdin, capture, pty)
+	} else {
+		if stdin != "" {
+			cmd.Stdin = strings.NewReader(stdin)
+		}
+		cmd.Stdout = capture.stdout
+		cmd.Stderr = capture.stderr
+		// Children that inherit the pipes must not keep Wait from returning.
+		cmd.WaitDelay = time.Second
+		err = cmd.Run()
+		// Wait only reports ErrWaitDelay when the command itself succeeded.
+		if errors.Is(err, exec.ErrWaitDelay) {
+			err = nil
+		}
+	}
+	stdout, stderr, truncated := capture.finish()
 
 	result := RunCommandResult{
-		Stdout:   strings.TrimRight(stdoutBuf.String(), "\n"),
-		Stderr:   strings.TrimRight(stderrBuf.String(), "\n"),
-		ExitCode: 0,
+		Stdout:    strings.TrimRight(cleanOutput(stdout, pty != nil, stripANSI), "\n"),
+		Stderr:    strings.TrimRight(cleanOutput(stderr, pty != nil, stripANSI), "\n"),
+		ExitCode:  0,
+		Truncated: truncated,
 	}
 
 	if err != nil {
@@ -77,3 +102,17 @@ func RunCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 
 	return result, nil
 }
+
+func runPtyCmd(cmd *exec.Cmd, stdin string, capture *outputCapture, pty *PtyOptions) error {
+	master, err := startPty(cmd, pty, stdin)
+	if err != nil {
+		return err
+	}
+
+	copied := make(chan struct{})
+	go copyPty(capture.stdout, master, copied)
+
+	err = cmd.Wait()
+	closePty(master, copied)
+	return err
+}
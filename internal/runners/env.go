@@ -0,0 +1,149 @@
+package runners
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+)
+
+const (
+	EnvInheritAll = "inherit-all"
+	EnvAllowlist  = "allowlist"
+	EnvClean      = "clean"
+)
+
+// DefaultEnvAllow is what the allowlist mode passes through when no allow
+// list is configured: enough for tools to find binaries, home and locale.
+var DefaultEnvAllow = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ", "TMPDIR",
+	"LANG", "LC_*",
+}
+
+type envOverride struct {
+	command *regexp.Regexp
+	set     map[string]string
+	unset   []*regexp.Regexp
+}
+
+// EnvPolicy decides which variables of the server's environment commands
+// started by run inherit, and what is set or removed on top.
+type EnvPolicy struct {
+	mode     string
+	allow    []*regexp.Regexp
+	unset    []*regexp.Regexp
+	set      map[string]string
+	commands []envOverride
+}
+
+func NewEnvPolicy(cfg config.RunEnvPolicyConfig) (*EnvPolicy, error) {
+	policy := &EnvPolicy{mode: EnvInheritAll, set: cfg.Set}
+	switch cfg.Mode {
+	case "", EnvInheritAll:
+	case EnvAllowlist, EnvClean:
+		policy.mode = cfg.Mode
+	default:
+		return nil, fmt.Errorf("invalid run.env_policy.mode: %s (expected inherit-all, allowlist or clean)", cfg.Mode)
+	}
+
+	allow := cfg.Allow
+	if len(allow) == 0 {
+		allow = DefaultEnvAllow
+	}
+	policy.allow = namePatterns(allow)
+	policy.unset = namePatterns(cfg.Unset)
+
+	for i, override := range cfg.Commands {
+		if override.Command == "" {
+			return nil, fmt.Errorf("run.env_policy.commands[%d]: command is required", i)
+		}
+		policy.commands = append(policy.commands, envOverride{
+			command: wildcardPattern(override.Command),
+			set:     override.Set,
+			unset:   namePatterns(override.Unset),
+		})
+	}
+
+	return policy, nil
+}
+
+func (p *EnvPolicy) Mode() string {
+	if p == nil {
+		return EnvInheritAll
+	}
+	return p.mode
+}
+
+// Environ builds the complete environment for command. The server's
+// environment is filtered by the mode (or skipped entirely when inherit is
+// false), then the policy's unset and set lists apply, then those of matching
+// per-command overrides, and finally the caller's own env.
+func (p *EnvPolicy) Environ(command string, inherit bool, env map[string]string) []string {
+	vars := make(map[string]string)
+	if inherit {
+		for key, value := range parseEnviron(os.Environ()) {
+			if p.inherits(key) {
+				vars[key] = value
+			}
+		}
+	}
+
+	if p != nil {
+		applyEnv(vars, p.unset, p.set)
+		for _, override := range p.commands {
+			if override.command.MatchString(command) || override.command.MatchString(filepath.Base(command)) {
+				applyEnv(vars, override.unset, override.set)
+			}
+		}
+	}
+
+	for key, value := range env {
+		vars[key] = value
+	}
+
+	environ := formatEnviron(vars)
+	sort.Strings(environ)
+	return environ
+}
+
+func (p *EnvPolicy) inherits(key string) bool {
+	switch p.Mode() {
+	case EnvClean:
+		return false
+	case EnvAllowlist:
+		return matchesAny(p.allow, key)
+	default:
+		return true
+	}
+}
+
+func applyEnv(vars map[string]string, unset []*regexp.Regexp, set map[string]string) {
+	for key := range vars {
+		if matchesAny(unset, key) {
+			delete(vars, key)
+		}
+	}
+	for key, value := range set {
+		vars[key] = value
+	}
+}
+
+func namePatterns(names []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(names))
+	for i, name := range names {
+		patterns[i] = wildcardPattern(name)
+	}
+	return patterns
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -204,7 +203,7 @@ func (r *FileRunner) Copy(ctx context.Context, input CopyInput) error {
 			if err := os.RemoveAll(dstAbs); err != nil {
 				return fmt.Errorf("failed to clear target: %w", err)
 			}
-		} else {
+		} else if srcInfo.IsDir() {
 			if err := os.Remove(dstAbs); err != nil {
 				return fmt.Errorf("failed to overwrite target: %w", err)
 			}
@@ -420,15 +419,5 @@ func copyFile(src, dst string, mode fs.FileMode) error {
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
-	if err != nil {
-		return err
-	}
-	defer dstFile.Close()
-
-	if _, err := io.Copy(dstFile, srcFile); err != nil {
-		return err
-	}
-
-	return nil
+	return writeAtomic(dst, srcFile, mode.Perm(), false)
 }
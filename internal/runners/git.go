@@ -11,6 +11,15 @@ import (
 
 type GitRunner struct{}
 
+// checkRef rejects revisions git would read as options, such as
+// "--output=FILE".
+func checkRef(name, ref string) error {
+	if strings.HasPrefix(ref, "-") {
+		return fmt.Errorf("invalid %s: %s (must not start with \"-\")", name, ref)
+	}
+	return nil
+}
+
 func NewGitRunner() *GitRunner {
 	return &GitRunner{}
 }
@@ -120,6 +129,13 @@ type GitDiffInput struct {
 }
 
 func (r *GitRunner) Diff(ctx context.Context, input GitDiffInput) (string, error) {
+	if err := checkRef("base", input.Base); err != nil {
+		return "", err
+	}
+	if err := checkRef("target", input.Target); err != nil {
+		return "", err
+	}
+
 	args := []string{"diff"}
 
 	if input.Staged {
@@ -177,6 +193,10 @@ type GitShowInput struct {
 }
 
 func (r *GitRunner) Show(ctx context.Context, input GitShowInput) (string, error) {
+	if err := checkRef("ref", input.Ref); err != nil {
+		return "", err
+	}
+
 	args := []string{"show"}
 
 	if input.Format != "" {
@@ -236,6 +256,10 @@ type GitBranchInput struct {
 }
 
 func (r *GitRunner) Branch(ctx context.Context, input GitBranchInput) (string, error) {
+	if err := checkRef("contains", input.Contains); err != nil {
+		return "", err
+	}
+
 	args := []string{"branch", "--list"}
 
 	if input.All {
@@ -0,0 +1,112 @@
+package runners
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	MIMETypePNG  = "image/png"
+	MIMETypeJPEG = "image/jpeg"
+	MIMETypeGIF  = "image/gif"
+	MIMETypeWebP = "image/webp"
+
+	// maxImagePixels bounds the images PrepareImage decodes to resize them.
+	// A small file can declare huge dimensions, and decoding allocates for
+	// every pixel up front.
+	maxImagePixels = 40_000_000
+)
+
+type ImageData struct {
+	MIMEType       string
+	Data           []byte
+	Width          int
+	Height         int
+	OriginalWidth  int
+	OriginalHeight int
+	Resized        bool
+}
+
+func SniffImageType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return MIMETypePNG
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return MIMETypeJPEG
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return MIMETypeGIF
+	case len(data) >= 12 && bytes.Equal(data[:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return MIMETypeWebP
+	}
+	return ""
+}
+
+// PrepareImage returns data unchanged when it already fits within maxDimension
+// pixels on its longest side (or maxDimension is 0). Larger images are scaled
+// down, re-encoded as JPEG when the source was JPEG and as PNG otherwise.
+// Images over 40 megapixels are refused rather than decoded.
+func PrepareImage(data []byte, mimeType string, maxDimension int) (ImageData, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImageData{}, fmt.Errorf("failed to decode %s header: %w", mimeType, err)
+	}
+
+	result := ImageData{
+		MIMEType:       mimeType,
+		Data:           data,
+		Width:          cfg.Width,
+		Height:         cfg.Height,
+		OriginalWidth:  cfg.Width,
+		OriginalHeight: cfg.Height,
+	}
+
+	if maxDimension <= 0 || (cfg.Width <= maxDimension && cfg.Height <= maxDimension) {
+		return result, nil
+	}
+
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > maxImagePixels {
+		return ImageData{}, fmt.Errorf("image is too large to resize: %dx%d pixels (limit %d megapixels)", cfg.Width, cfg.Height, maxImagePixels/1_000_000)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ImageData{}, fmt.Errorf("failed to decode %s: %w", mimeType, err)
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if width >= height {
+		height = max(1, height*maxDimension/width)
+		width = maxDimension
+	} else {
+		width = max(1, width*maxDimension/height)
+		height = maxDimension
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if mimeType == MIMETypeJPEG {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85})
+	} else {
+		mimeType = MIMETypePNG
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		return ImageData{}, fmt.Errorf("failed to encode resized image: %w", err)
+	}
+
+	result.MIMEType = mimeType
+	result.Data = buf.Bytes()
+	result.Width = width
+	result.Height = height
+	result.Resized = true
+	return result, nil
+}
@@ -0,0 +1,194 @@
+package runners
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+)
+
+const (
+	// IsolationHelperArg is the first argument of the server binary when it is
+	// re-executed to apply limits and enter the sandbox before running a command.
+	IsolationHelperArg = "__code-tools-isolate"
+
+	isolationSpecEnv = "CODE_TOOLS_ISOLATION_SPEC"
+)
+
+type ResourceLimits struct {
+	CPUSeconds   uint64 `json:"cpu_seconds,omitempty"`
+	AddressSpace uint64 `json:"address_space,omitempty"`
+	OpenFiles    uint64 `json:"open_files,omitempty"`
+	// Processes is RLIMIT_NPROC. The kernel counts every process of the
+	// user against it, not just the command and its children.
+	Processes uint64 `json:"processes,omitempty"`
+}
+
+func (l ResourceLimits) empty() bool {
+	return l == ResourceLimits{}
+}
+
+// isolationSpec is what the helper needs to set up the child before it execs
+// the real command.
+type isolationSpec struct {
+	Path     string         `json:"path"`
+	Args     []string       `json:"args"`
+	Limits   ResourceLimits `json:"limits"`
+	Sandbox  bool           `json:"sandbox,omitempty"`
+	Network  bool           `json:"network,omitempty"`
+	Writable []string       `json:"writable,omitempty"`
+}
+
+// Isolation applies run.limits and run.sandbox to commands. Commands are
+// started through the server binary (see RunIsolationHelper), which sets the
+// rlimits and mounts in the child and then execs the command.
+type Isolation struct {
+	helper   string
+	limits   ResourceLimits
+	sandbox  bool
+	network  bool
+	writable []string
+}
+
+func NewIsolation(cfg config.RunConfig, workspaceRoots []string) (*Isolation, error) {
+	limits := cfg.Limits
+	if limits.CPUSeconds < 0 || limits.AddressSpaceMB < 0 || limits.OpenFiles < 0 || limits.Processes < 0 {
+		return nil, fmt.Errorf("run.limits values must not be negative")
+	}
+
+	isolation := &Isolation{
+		limits: ResourceLimits{
+			CPUSeconds:   uint64(limits.CPUSeconds),
+			AddressSpace: uint64(limits.AddressSpaceMB) * 1024 * 1024,
+			OpenFiles:    uint64(limits.OpenFiles),
+			Processes:    uint64(limits.Processes),
+		},
+		sandbox: cfg.Sandbox.Enabled,
+		network: cfg.Sandbox.Network,
+	}
+	if !isolation.enabled() {
+		return isolation, nil
+	}
+
+	if !isolation.limits.empty() && !limitsSupported {
+		return nil, fmt.Errorf("run.limits are not supported on this platform")
+	}
+	if isolation.sandbox {
+		if !sandboxSupported {
+			return nil, fmt.Errorf("run.sandbox is only supported on Linux")
+		}
+		for _, path := range append(append([]string(nil), workspaceRoots...), cfg.Sandbox.Writable...) {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid run.sandbox.writable path %s: %w", path, err)
+			}
+			if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+				abs = resolved
+			}
+			isolation.writable = append(isolation.writable, abs)
+		}
+	}
+
+	helper, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate server binary for run isolation: %w", err)
+	}
+	isolation.helper = helper
+
+	return isolation, nil
+}
+
+func (i *Isolation) enabled() bool {
+	return i != nil && (i.sandbox || !i.limits.empty())
+}
+
+// Describe summarizes the active isolation for logs.
+func (i *Isolation) Describe() []any {
+	if !i.enabled() {
+		return []any{"isolation", "none"}
+	}
+	args := []any{"limits", i.limits, "sandbox", i.sandbox}
+	if i.sandbox {
+		args = append(args, "network", i.network, "writable", i.writable)
+	}
+	return args
+}
+
+// prepareCmd puts cmd in its own process group and, when isolation is
+// enabled, routes it through the isolation helper. extraWritable are paths the
+// runner itself needs to write inside the sandbox.
+func (i *Isolation) prepareCmd(cmd *exec.Cmd, extraWritable ...string) error {
+	setProcessGroup(cmd)
+
+	if !i.enabled() || cmd.Err != nil {
+		return nil
+	}
+
+	spec := isolationSpec{
+		Path:    cmd.Path,
+		Args:    cmd.Args,
+		Limits:  i.limits,
+		Sandbox: i.sandbox,
+		Network: i.network,
+	}
+	if i.sandbox {
+		spec.Writable = append(append([]string(nil), i.writable...), extraWritable...)
+		setSandboxAttrs(cmd, i.network)
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("failed to encode isolation spec: %w", err)
+	}
+
+	env := cmd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	cmd.Env = append(append([]string(nil), env...), isolationSpecEnv+"="+string(data))
+	cmd.Path = i.helper
+	cmd.Args = []string{i.helper, IsolationHelperArg}
+	return nil
+}
+
+// RunIsolationHelper takes over the process when the server binary was
+// started as an isolation helper: it applies the spec from the environment
+// and execs the command, never returning. Otherwise it returns immediately.
+func RunIsolationHelper() {
+	if len(os.Args) < 2 || os.Args[1] != IsolationHelperArg {
+		return
+	}
+
+	if err := runIsolated(); err != nil {
+		fmt.Fprintf(os.Stderr, "code-tools isolation: %v\n", err)
+		os.Exit(126)
+	}
+}
+
+func runIsolated() error {
+	var spec isolationSpec
+	if err := json.Unmarshal([]byte(os.Getenv(isolationSpecEnv)), &spec); err != nil {
+		return fmt.Errorf("invalid isolation spec: %w", err)
+	}
+
+	var env []string
+	for _, entry := range os.Environ() {
+		if !strings.HasPrefix(entry, isolationSpecEnv+"=") {
+			env = append(env, entry)
+		}
+	}
+
+	if spec.Sandbox {
+		if err := enterSandbox(spec.Writable, spec.Network); err != nil {
+			return err
+		}
+	}
+	if err := applyLimits(spec.Limits); err != nil {
+		return err
+	}
+	return execCommand(spec.Path, spec.Args, env)
+}
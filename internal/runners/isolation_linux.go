@@ -0,0 +1,183 @@
+//go:build linux
+
+package runners
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	sandboxSupported = true
+
+	rlimitNproc = 6
+
+	// statfs(2) mount flags, which syscall does not export.
+	stRdonly     = 0x1
+	stNosuid     = 0x2
+	stNodev      = 0x4
+	stNoexec     = 0x8
+	stNoatime    = 0x400
+	stNodiratime = 0x800
+	stRelatime   = 0x1000
+)
+
+// setSandboxAttrs starts the helper in new user and mount namespaces (and a
+// network namespace unless network is allowed), mapped to the server's own
+// uid and gid.
+func setSandboxAttrs(cmd *exec.Cmd, network bool) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS
+	if !network {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+}
+
+// enterSandbox runs inside the helper's new namespaces. It remounts every
+// mount read-only except the writable paths, which are first bound onto
+// themselves so they become separate mounts that keep their write access.
+// Nothing else is special-cased: /tmp and GOCACHE end up read-only too.
+func enterSandbox(writable []string, network bool) error {
+	wd, _ := os.Getwd()
+
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("failed to make mounts private: %w", err)
+	}
+
+	for _, path := range writable {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := syscall.Mount(path, path, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("failed to bind %s: %w", path, err)
+		}
+	}
+
+	mountPoints, err := readMountPoints()
+	if err != nil {
+		return err
+	}
+	for _, mountPoint := range mountPoints {
+		if withinAny(mountPoint, writable) {
+			continue
+		}
+
+		var stat syscall.Statfs_t
+		if err := syscall.Statfs(mountPoint, &stat); err != nil {
+			// Unreachable mounts (e.g. under a directory we cannot search)
+			// are unreachable for the command too.
+			continue
+		}
+		if stat.Flags&stRdonly != 0 {
+			continue
+		}
+
+		// Remounts in a user namespace must keep the flags the mount
+		// already has, or the kernel refuses them.
+		flags := uintptr(syscall.MS_REMOUNT | syscall.MS_BIND | syscall.MS_RDONLY)
+		for _, flag := range []struct{ st, ms int64 }{
+			{stNosuid, syscall.MS_NOSUID},
+			{stNodev, syscall.MS_NODEV},
+			{stNoexec, syscall.MS_NOEXEC},
+			{stNoatime, syscall.MS_NOATIME},
+			{stNodiratime, syscall.MS_NODIRATIME},
+			{stRelatime, syscall.MS_RELATIME},
+		} {
+			if int64(stat.Flags)&flag.st != 0 {
+				flags |= uintptr(flag.ms)
+			}
+		}
+		if err := syscall.Mount("", mountPoint, "", flags, ""); err != nil {
+			return fmt.Errorf("failed to make %s read-only: %w", mountPoint, err)
+		}
+	}
+
+	if !network {
+		if err := loopbackUp(); err != nil {
+			return err
+		}
+	}
+
+	// The working directory still refers to the mount it was opened on;
+	// re-enter it so writable binds over it take effect.
+	if wd != "" {
+		if err := os.Chdir(wd); err != nil {
+			return fmt.Errorf("failed to enter %s: %w", wd, err)
+		}
+	}
+	return nil
+}
+
+func readMountPoints() ([]string, error) {
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+	defer file.Close()
+
+	var mountPoints []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mountPoints = append(mountPoints, unescapeMountPoint(fields[4]))
+	}
+	return mountPoints, scanner.Err()
+}
+
+// unescapeMountPoint decodes the octal escapes (\040 for space and so on)
+// used in /proc/self/mountinfo.
+func unescapeMountPoint(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var builder strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if value, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				builder.WriteByte(byte(value))
+				i += 3
+				continue
+			}
+		}
+		builder.WriteByte(s[i])
+	}
+	return builder.String()
+}
+
+// loopbackUp brings up lo in the new network namespace so commands can still
+// talk to servers they start on localhost.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return fmt.Errorf("failed to bring up loopback: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	var req struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(req.name[:], "lo")
+	req.flags = syscall.IFF_UP | syscall.IFF_RUNNING
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), syscall.SIOCSIFFLAGS, uintptr(unsafe.Pointer(&req))); errno != 0 {
+		return fmt.Errorf("failed to bring up loopback: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+
+package runners
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+const (
+	sandboxSupported = false
+
+	// RLIMIT_NPROC on Darwin and the BSDs.
+	rlimitNproc = 7
+)
+
+func setSandboxAttrs(cmd *exec.Cmd, network bool) {}
+
+func enterSandbox(writable []string, network bool) error {
+	return fmt.Errorf("sandbox is not supported on this platform")
+}
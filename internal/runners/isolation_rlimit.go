@@ -0,0 +1,9 @@
+//go:build !windows && !freebsd
+
+package runners
+
+import "syscall"
+
+func newRlimit(value uint64) *syscall.Rlimit {
+	return &syscall.Rlimit{Cur: value, Max: value}
+}
@@ -0,0 +1,9 @@
+package runners
+
+import "syscall"
+
+// FreeBSD's rlim_t is signed. Limits come from non-negative config ints, so
+// they fit.
+func newRlimit(value uint64) *syscall.Rlimit {
+	return &syscall.Rlimit{Cur: int64(value), Max: int64(value)}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package runners
+
+import (
+	"fmt"
+	"syscall"
+)
+
+const limitsSupported = true
+
+func applyLimits(limits ResourceLimits) error {
+	for _, limit := range []struct {
+		name     string
+		resource int
+		value    uint64
+	}{
+		{"cpu_seconds", syscall.RLIMIT_CPU, limits.CPUSeconds},
+		{"address_space", syscall.RLIMIT_AS, limits.AddressSpace},
+		{"open_files", syscall.RLIMIT_NOFILE, limits.OpenFiles},
+		{"processes", rlimitNproc, limits.Processes},
+	} {
+		if limit.value == 0 {
+			continue
+		}
+		if err := syscall.Setrlimit(limit.resource, newRlimit(limit.value)); err != nil {
+			return fmt.Errorf("failed to set %s limit: %w", limit.name, err)
+		}
+	}
+	return nil
+}
+
+func execCommand(path string, args []string, env []string) error {
+	if err := syscall.Exec(path, args, env); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", path, err)
+	}
+	return nil
+}
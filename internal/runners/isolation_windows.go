@@ -0,0 +1,27 @@
+//go:build windows
+
+package runners
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+const (
+	limitsSupported  = false
+	sandboxSupported = false
+)
+
+func setSandboxAttrs(cmd *exec.Cmd, network bool) {}
+
+func enterSandbox(writable []string, network bool) error {
+	return fmt.Errorf("sandbox is not supported on windows")
+}
+
+func applyLimits(limits ResourceLimits) error {
+	return fmt.Errorf("resource limits are not supported on windows")
+}
+
+func execCommand(path string, args []string, env []string) error {
+	return fmt.Errorf("exec is not supported on windows")
+}
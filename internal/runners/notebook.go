@@ -0,0 +1,359 @@
+package runners
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const notebookOutputLimit = 2000
+
+type NotebookCell struct {
+	Index          int
+	ID             string
+	CellType       string
+	Source         string
+	ExecutionCount *int
+	Outputs        []string
+}
+
+type Notebook struct {
+	top     map[string]json.RawMessage
+	cells   []map[string]json.RawMessage
+	indent  string
+	newline bool
+}
+
+func ParseNotebook(data []byte) (*Notebook, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse notebook: %w", err)
+	}
+
+	nb := &Notebook{
+		top:     top,
+		indent:  detectJSONIndent(data),
+		newline: bytes.HasSuffix(data, []byte("\n")),
+	}
+
+	if raw, ok := top["cells"]; ok {
+		if err := json.Unmarshal(raw, &nb.cells); err != nil {
+			return nil, fmt.Errorf("failed to parse notebook cells: %w", err)
+		}
+	} else {
+		return nil, fmt.Errorf("not a Jupyter notebook: missing cells")
+	}
+
+	return nb, nil
+}
+
+func (nb *Notebook) Cells() []NotebookCell {
+	cells := make([]NotebookCell, len(nb.cells))
+	for i, raw := range nb.cells {
+		cell := NotebookCell{Index: i}
+		_ = json.Unmarshal(raw["id"], &cell.ID)
+		_ = json.Unmarshal(raw["cell_type"], &cell.CellType)
+		cell.Source = decodeMultiline(raw["source"])
+		if count, ok := raw["execution_count"]; ok {
+			_ = json.Unmarshal(count, &cell.ExecutionCount)
+		}
+		cell.Outputs = renderOutputs(raw["outputs"])
+		cells[i] = cell
+	}
+	return cells
+}
+
+func (nb *Notebook) Render() string {
+	var builder strings.Builder
+	for i, cell := range nb.Cells() {
+		if i > 0 {
+			builder.WriteString("\n\n")
+		}
+		builder.WriteString(fmt.Sprintf("Cell %d [%s] id=%s", cell.Index+1, cell.CellType, cell.Ref()))
+		if cell.ExecutionCount != nil {
+			builder.WriteString(fmt.Sprintf(" execution_count=%d", *cell.ExecutionCount))
+		}
+		builder.WriteString("\n")
+		builder.WriteString(cell.Source)
+		for _, output := range cell.Outputs {
+			builder.WriteString("\n--- output ---\n")
+			builder.WriteString(output)
+		}
+	}
+	if len(nb.cells) == 0 {
+		return "(empty notebook)"
+	}
+	return builder.String()
+}
+
+type NotebookEdit struct {
+	CellID   string
+	Source   string
+	CellType string
+	Mode     string
+}
+
+// Apply replaces, inserts or deletes a cell. Inserted cells go after CellID,
+// or at the top of the notebook when CellID is empty. It returns the id of
+// the affected cell.
+func (nb *Notebook) Apply(edit NotebookEdit) (string, error) {
+	mode := edit.Mode
+	if mode == "" {
+		mode = "replace"
+	}
+
+	index := -1
+	if edit.CellID != "" {
+		index = nb.findCell(edit.CellID)
+		if index < 0 {
+			return "", fmt.Errorf("cell not found: %s", edit.CellID)
+		}
+	} else if mode != "insert" {
+		return "", fmt.Errorf("cell_id is required for %s", mode)
+	}
+
+	switch mode {
+	case "replace":
+		cell := nb.cells[index]
+		if edit.CellType != "" {
+			if err := setCellType(cell, edit.CellType); err != nil {
+				return "", err
+			}
+		}
+		cell["source"] = encodeMultiline(edit.Source)
+		return nb.Cells()[index].Ref(), nil
+
+	case "insert":
+		cellType := edit.CellType
+		if cellType == "" {
+			cellType = "code"
+		}
+		cell := map[string]json.RawMessage{
+			"metadata": json.RawMessage("{}"),
+			"source":   encodeMultiline(edit.Source),
+		}
+		if err := setCellType(cell, cellType); err != nil {
+			return "", err
+		}
+
+		id := ""
+		if nb.usesCellIDs() {
+			id = newNotebookCellID()
+			cell["id"] = mustMarshal(id)
+		}
+
+		position := index + 1
+		nb.cells = append(nb.cells, nil)
+		copy(nb.cells[position+1:], nb.cells[position:])
+		nb.cells[position] = cell
+
+		if id == "" {
+			id = "cell-" + strconv.Itoa(position)
+		}
+		return id, nil
+
+	case "delete":
+		ref := nb.Cells()[index].Ref()
+		nb.cells = append(nb.cells[:index], nb.cells[index+1:]...)
+		return ref, nil
+
+	default:
+		return "", fmt.Errorf("invalid edit_mode: %s (expected replace, insert or delete)", mode)
+	}
+}
+
+func (nb *Notebook) Marshal() ([]byte, error) {
+	cells := make([]json.RawMessage, len(nb.cells))
+	for i, cell := range nb.cells {
+		cells[i] = marshalSorted(cell)
+	}
+	nb.top["cells"] = mustMarshal(cells)
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", nb.indent)
+	if err := encoder.Encode(marshalSorted(nb.top)); err != nil {
+		return nil, err
+	}
+
+	data := buf.Bytes()
+	if !nb.newline {
+		data = bytes.TrimSuffix(data, []byte("\n"))
+	}
+	return data, nil
+}
+
+func (nb *Notebook) findCell(ref string) int {
+	for i, cell := range nb.cells {
+		var id string
+		if json.Unmarshal(cell["id"], &id) == nil && id == ref {
+			return i
+		}
+	}
+	if strings.HasPrefix(ref, "cell-") {
+		if i, err := strconv.Atoi(strings.TrimPrefix(ref, "cell-")); err == nil && i >= 0 && i < len(nb.cells) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (nb *Notebook) usesCellIDs() bool {
+	for _, cell := range nb.cells {
+		if _, ok := cell["id"]; ok {
+			return true
+		}
+	}
+	var minor int
+	_ = json.Unmarshal(nb.top["nbformat_minor"], &minor)
+	return minor >= 5
+}
+
+func (c NotebookCell) Ref() string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return "cell-" + strconv.Itoa(c.Index)
+}
+
+func setCellType(cell map[string]json.RawMessage, cellType string) error {
+	switch cellType {
+	case "code":
+		if _, ok := cell["outputs"]; !ok {
+			cell["outputs"] = json.RawMessage("[]")
+		}
+		if _, ok := cell["execution_count"]; !ok {
+			cell["execution_count"] = json.RawMessage("null")
+		}
+	case "markdown", "raw":
+		delete(cell, "outputs")
+		delete(cell, "execution_count")
+	default:
+		return fmt.Errorf("invalid cell_type: %s (expected code, markdown or raw)", cellType)
+	}
+	cell["cell_type"] = mustMarshal(cellType)
+	return nil
+}
+
+func renderOutputs(raw json.RawMessage) []string {
+	var outputs []map[string]json.RawMessage
+	if len(raw) == 0 || json.Unmarshal(raw, &outputs) != nil {
+		return nil
+	}
+
+	var rendered []string
+	for _, output := range outputs {
+		var outputType string
+		_ = json.Unmarshal(output["output_type"], &outputType)
+
+		var text string
+		switch outputType {
+		case "stream":
+			text = decodeMultiline(output["text"])
+		case "execute_result", "display_data":
+			var data map[string]json.RawMessage
+			_ = json.Unmarshal(output["data"], &data)
+			if plain, ok := data["text/plain"]; ok {
+				text = decodeMultiline(plain)
+			} else {
+				mimeTypes := make([]string, 0, len(data))
+				for mimeType := range data {
+					mimeTypes = append(mimeTypes, mimeType)
+				}
+				sort.Strings(mimeTypes)
+				text = fmt.Sprintf("[%s output]", strings.Join(mimeTypes, ", "))
+			}
+		case "error":
+			var name, value string
+			_ = json.Unmarshal(output["ename"], &name)
+			_ = json.Unmarshal(output["evalue"], &value)
+			text = fmt.Sprintf("%s: %s", name, value)
+		default:
+			continue
+		}
+
+		text = strings.TrimRight(text, "\n")
+		if len(text) > notebookOutputLimit {
+			text = truncateUTF8(text, notebookOutputLimit) + "... (truncated)"
+		}
+		rendered = append(rendered, text)
+	}
+	return rendered
+}
+
+func decodeMultiline(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var lines []string
+	if json.Unmarshal(raw, &lines) == nil {
+		return strings.Join(lines, "")
+	}
+	return ""
+}
+
+func encodeMultiline(text string) json.RawMessage {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if lines == nil {
+		lines = []string{}
+	}
+	return mustMarshal(lines)
+}
+
+func marshalSorted(fields map[string]json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(fields)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		panic(err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func detectJSONIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n"))[1:] {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 {
+			continue
+		}
+		return string(line[:len(line)-len(trimmed)])
+	}
+	return " "
+}
+
+func newNotebookCellID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
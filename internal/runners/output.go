@@ -0,0 +1,361 @@
+package runners
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+
+	DefaultMaxOutputBytes = 32 * 1024
+	// maxOutputChunk caps a single Read of spooled output.
+	maxOutputChunk = 1 << 20
+
+	// maxSpooledOutputs bounds how many runs keep their full output on disk.
+	maxSpooledOutputs = 16
+)
+
+// OutputTruncation describes a run whose output exceeded the per-stream cap.
+// The full output of each truncated stream can be read back from the spool
+// under OutputID.
+type OutputTruncation struct {
+	OutputID        string `json:"output_id,omitempty"`
+	StdoutBytes     int64  `json:"stdout_bytes"`
+	StderrBytes     int64  `json:"stderr_bytes"`
+	StdoutTruncated bool   `json:"stdout_truncated"`
+	StderrTruncated bool   `json:"stderr_truncated"`
+}
+
+type OutputChunk struct {
+	Content    string
+	NextOffset int64
+	TotalBytes int64
+	EOF        bool
+}
+
+// OutputSpool keeps the complete output of truncated runs in a temporary
+// directory so it can be paged through after the run returns.
+type OutputSpool struct {
+	mu      sync.Mutex
+	dir     string
+	entries []string
+	closed  bool
+}
+
+func NewOutputSpool() *OutputSpool {
+	return &OutputSpool{}
+}
+
+func (s *OutputSpool) newID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return "", fmt.Errorf("output spool is closed")
+	}
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "code-tools-run-output-*")
+		if err != nil {
+			return "", fmt.Errorf("failed to create output spool: %w", err)
+		}
+		s.dir = dir
+	}
+
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	s.entries = append(s.entries, id)
+	for len(s.entries) > maxSpooledOutputs {
+		s.removeFiles(s.entries[0])
+		s.entries = s.entries[1:]
+	}
+	return id, nil
+}
+
+func (s *OutputSpool) path(id, stream string) string {
+	return filepath.Join(s.dir, id+"."+stream)
+}
+
+func (s *OutputSpool) removeFiles(id string) {
+	os.Remove(s.path(id, StreamStdout))
+	os.Remove(s.path(id, StreamStderr))
+}
+
+func (s *OutputSpool) create(id, stream string) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.OpenFile(s.path(id, stream), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+}
+
+// Read returns up to length bytes (at most 1 MiB) of a spooled stream starting
+// at offset. The chunk never ends inside a UTF-8 sequence unless it reaches the end of the
+// stream.
+func (s *OutputSpool) Read(id, stream string, offset int64, length int) (OutputChunk, error) {
+	if stream != StreamStdout && stream != StreamStderr {
+		return OutputChunk{}, fmt.Errorf("invalid stream: %s (expected stdout or stderr)", stream)
+	}
+	if length <= 0 {
+		length = DefaultMaxOutputBytes
+	}
+	if offset < 0 {
+		return OutputChunk{}, fmt.Errorf("offset must not be negative")
+	}
+
+	s.mu.Lock()
+	known := false
+	for _, entry := range s.entries {
+		known = known || entry == id
+	}
+	path := s.path(id, stream)
+	s.mu.Unlock()
+
+	if !known {
+		return OutputChunk{}, fmt.Errorf("output not found: %s (only the last %d truncated runs are kept)", id, maxSpooledOutputs)
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return OutputChunk{}, fmt.Errorf("%s of %s was not truncated; its full output is in the run result", stream, id)
+	}
+	if err != nil {
+		return OutputChunk{}, fmt.Errorf("failed to open spooled output: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return OutputChunk{}, fmt.Errorf("failed to stat spooled output: %w", err)
+	}
+	total := info.Size()
+	if offset > total {
+		offset = total
+	}
+	if length > maxOutputChunk {
+		length = maxOutputChunk
+	}
+	if remaining := total - offset; int64(length) > remaining {
+		length = int(remaining)
+	}
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return OutputChunk{}, fmt.Errorf("failed to read spooled output: %w", err)
+	}
+	buf = buf[:n]
+
+	next := offset + int64(n)
+	if next < total {
+		buf = TrimPartialRune(buf)
+		next = offset + int64(len(buf))
+	}
+
+	return OutputChunk{
+		Content:    string(buf),
+		NextOffset: next,
+		TotalBytes: total,
+		EOF:        next >= total,
+	}, nil
+}
+
+// Close deletes all spooled output.
+func (s *OutputSpool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	s.entries = nil
+	if s.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// OutputFunc receives each chunk a command writes, as it is written, before
+// any truncation. It may be called concurrently for stdout and stderr.
+type OutputFunc func(stream string, chunk []byte)
+
+// outputCapture collects the stdout and stderr of one run under a shared cap.
+type outputCapture struct {
+	spool    *OutputSpool
+	onOutput OutputFunc
+
+	mu       sync.Mutex
+	id       string
+	spoolErr error
+
+	stdout *cappedBuffer
+	stderr *cappedBuffer
+}
+
+func newOutputCapture(maxBytes int, spool *OutputSpool, onOutput OutputFunc) *outputCapture {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxOutputBytes
+	}
+	c := &outputCapture{spool: spool, onOutput: onOutput}
+	c.stdout = &cappedBuffer{capture: c, stream: StreamStdout, limit: maxBytes}
+	c.stderr = &cappedBuffer{capture: c, stream: StreamStderr, limit: maxBytes}
+	return c
+}
+
+// spill opens the spool file for a stream that just overflowed. Output is
+// still truncated if spooling is unavailable; only the continuation is lost.
+func (c *outputCapture) spill(stream string) *os.File {
+	if c.spool == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.spoolErr != nil {
+		return nil
+	}
+	if c.id == "" {
+		id, err := c.spool.newID()
+		if err != nil {
+			c.spoolErr = err
+			return nil
+		}
+		c.id = id
+	}
+
+	file, err := c.spool.create(c.id, stream)
+	if err != nil {
+		c.spoolErr = err
+		return nil
+	}
+	return file
+}
+
+func (c *outputCapture) finish() (string, string, *OutputTruncation) {
+	stdout, stdoutCut := c.stdout.finish()
+	stderr, stderrCut := c.stderr.finish()
+	if !stdoutCut && !stderrCut {
+		return stdout, stderr, nil
+	}
+	return stdout, stderr, &OutputTruncation{
+		OutputID:        c.id,
+		StdoutBytes:     c.stdout.total,
+		StderrBytes:     c.stderr.total,
+		StdoutTruncated: stdoutCut,
+		StderrTruncated: stderrCut,
+	}
+}
+
+// cappedBuffer keeps a stream whole while it fits in limit bytes. Past that it
+// keeps the first and last limit/2 bytes in memory and writes everything to
+// the spool.
+type cappedBuffer struct {
+	capture *outputCapture
+	stream  string
+	limit   int
+
+	data  []byte
+	head  []byte
+	tail  []byte
+	total int64
+	cut   bool
+	file  *os.File
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.capture.onOutput != nil {
+		b.capture.onOutput(b.stream, p)
+	}
+	b.total += int64(len(p))
+
+	if !b.cut {
+		b.data = append(b.data, p...)
+		if len(b.data) <= b.limit {
+			return len(p), nil
+		}
+
+		b.cut = true
+		b.file = b.capture.spill(b.stream)
+		b.writeFile(b.data)
+		half := b.limit / 2
+		b.head = append([]byte(nil), b.data[:half]...)
+		b.tail = append([]byte(nil), b.data[half:]...)
+		b.data = nil
+	} else {
+		b.writeFile(p)
+		b.tail = append(b.tail, p...)
+	}
+
+	if keep := b.limit - b.limit/2; len(b.tail) > 2*keep {
+		b.tail = append([]byte(nil), b.tail[len(b.tail)-keep:]...)
+	}
+	return len(p), nil
+}
+
+func (b *cappedBuffer) writeFile(p []byte) {
+	if b.file == nil {
+		return
+	}
+	if _, err := b.file.Write(p); err != nil {
+		b.file.Close()
+		b.file = nil
+	}
+}
+
+func (b *cappedBuffer) finish() (string, bool) {
+	if b.file != nil {
+		b.file.Close()
+		b.file = nil
+	}
+	if !b.cut {
+		return string(b.data), false
+	}
+
+	keep := b.limit - b.limit/2
+	tail := b.tail
+	if len(tail) > keep {
+		tail = tail[len(tail)-keep:]
+	}
+	head := TrimPartialRune(b.head)
+	tail = TrimLeadingPartialRune(tail)
+
+	elided := b.total - int64(len(head)) - int64(len(tail))
+	var out bytes.Buffer
+	out.Write(head)
+	fmt.Fprintf(&out, "\n\n... [%d bytes elided] ...\n\n", elided)
+	out.Write(tail)
+	return out.String(), true
+}
+
+// TrimPartialRune drops an incomplete UTF-8 sequence from the end of p.
+func TrimPartialRune(p []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(p); i++ {
+		if utf8.RuneStart(p[len(p)-i]) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return p[:len(p)-i]
+			}
+			break
+		}
+	}
+	return p
+}
+
+// TrimLeadingPartialRune drops the continuation bytes of a UTF-8 sequence
+// cut off at the start of p.
+func TrimLeadingPartialRune(p []byte) []byte {
+	for i := 0; i < utf8.UTFMax && i < len(p); i++ {
+		if utf8.RuneStart(p[i]) {
+			return p[i:]
+		}
+	}
+	return p
+}
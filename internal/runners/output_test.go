@@ -0,0 +1,204 @@
+package runners
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTrimPartialRune(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"abé", "abé"},
+		{"ab\xc3", "ab"},
+		{"ab\xe2\x82", "ab"},
+		{"ab€", "ab€"},
+		{"ab\xf0\x9f\x98", "ab"},
+		{"ab😀", "ab😀"},
+		{"\x82\xac", "\x82\xac"},
+	}
+	for _, tt := range tests {
+		if got := string(TrimPartialRune([]byte(tt.in))); got != tt.want {
+			t.Errorf("TrimPartialRune(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTrimLeadingPartialRune(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"\xa9bc", "bc"},
+		{"\x82\xacc", "c"},
+		{"\x9f\x98\x80é", "é"},
+		{"\x80\x80\x80\x80", "\x80\x80\x80\x80"},
+	}
+	for _, tt := range tests {
+		if got := string(TrimLeadingPartialRune([]byte(tt.in))); got != tt.want {
+			t.Errorf("TrimLeadingPartialRune(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestOutputCaptureTruncation(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+		cut    bool
+	}{
+		{"fits", 8, []string{"abcd", "efgh"}, "abcdefgh", false},
+		{"plain cut", 8, []string{"abcdefghijkl"}, "abcd\n\n... [4 bytes elided] ...\n\nijkl", true},
+		// The head would end after the first byte of "é".
+		{"head mid-rune", 8, []string{"abcé", "xxxxxx"}, "abc\n\n... [4 bytes elided] ...\n\nxxxx", true},
+		// The tail would start after the first byte of "€".
+		{"tail mid-rune", 8, []string{"aaaaaa€cd"}, "aaaa\n\n... [5 bytes elided] ...\n\ncd", true},
+		{"many small writes", 8, strings.Split("0123456789abcdefghij", ""), "0123\n\n... [12 bytes elided] ...\n\nghij", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := newOutputCapture(tt.limit, nil, nil)
+			for _, w := range tt.writes {
+				capture.stdout.Write([]byte(w))
+			}
+			stdout, stderr, truncation := capture.finish()
+			if stdout != tt.want {
+				t.Errorf("stdout = %q, want %q", stdout, tt.want)
+			}
+			if stderr != "" {
+				t.Errorf("stderr = %q, want empty", stderr)
+			}
+			if (truncation != nil) != tt.cut {
+				t.Fatalf("truncation = %+v, want cut %v", truncation, tt.cut)
+			}
+			if truncation != nil && (!truncation.StdoutTruncated || truncation.StderrTruncated || truncation.OutputID != "") {
+				t.Errorf("truncation = %+v, want only stdout truncated and no output id", truncation)
+			}
+		})
+	}
+}
+
+func TestOutputCaptureSpool(t *testing.T) {
+	spool := NewOutputSpool()
+	defer spool.Close()
+
+	var seen bytes.Buffer
+	capture := newOutputCapture(8, spool, func(stream string, chunk []byte) {
+		if stream == StreamStdout {
+			seen.Write(chunk)
+		}
+	})
+	full := "héllo wörld, this is long"
+	for _, w := range []string{"héllo ", "wörld, ", "this is long"} {
+		capture.stdout.Write([]byte(w))
+	}
+	capture.stderr.Write([]byte("short"))
+
+	_, stderr, truncation := capture.finish()
+	if stderr != "short" {
+		t.Errorf("stderr = %q, want %q", stderr, "short")
+	}
+	if truncation == nil || truncation.OutputID == "" {
+		t.Fatalf("truncation = %+v, want a spooled output id", truncation)
+	}
+	if truncation.StdoutBytes != int64(len(full)) || truncation.StderrBytes != 5 {
+		t.Errorf("truncation bytes = %d/%d, want %d/5", truncation.StdoutBytes, truncation.StderrBytes, len(full))
+	}
+	if seen.String() != full {
+		t.Errorf("onOutput saw %q, want %q", seen.String(), full)
+	}
+
+	chunk, err := spool.Read(truncation.OutputID, StreamStdout, 0, 0)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if chunk.Content != full || !chunk.EOF || chunk.TotalBytes != int64(len(full)) {
+		t.Errorf("Read = %+v, want all of %q", chunk, full)
+	}
+
+	if _, err := spool.Read(truncation.OutputID, StreamStderr, 0, 0); err == nil || !strings.Contains(err.Error(), "was not truncated") {
+		t.Errorf("Read of untruncated stderr error = %v, want not truncated", err)
+	}
+	if _, err := spool.Read("missing", StreamStdout, 0, 0); err == nil || !strings.Contains(err.Error(), "output not found") {
+		t.Errorf("Read of unknown id error = %v, want not found", err)
+	}
+	if _, err := spool.Read(truncation.OutputID, "stdin", 0, 0); err == nil {
+		t.Errorf("Read of invalid stream succeeded")
+	}
+	if _, err := spool.Read(truncation.OutputID, StreamStdout, -1, 0); err == nil {
+		t.Errorf("Read at a negative offset succeeded")
+	}
+}
+
+func TestOutputSpoolRead(t *testing.T) {
+	spool := NewOutputSpool()
+	defer spool.Close()
+
+	// "é" occupies bytes 2-3 and "€" bytes 6-8.
+	content := "abéde€fg"
+	capture := newOutputCapture(4, spool, nil)
+	capture.stdout.Write([]byte(content))
+	_, _, truncation := capture.finish()
+	id := truncation.OutputID
+
+	tests := []struct {
+		name   string
+		offset int64
+		length int
+		want   string
+		next   int64
+		eof    bool
+	}{
+		{"whole", 0, 100, content, 11, true},
+		{"prefix", 0, 2, "ab", 2, false},
+		{"ends mid-rune", 0, 3, "ab", 2, false},
+		{"continues", 2, 5, "éde", 6, false},
+		{"ends mid-rune later", 4, 4, "de", 6, false},
+		{"rest", 6, 32, "€fg", 11, true},
+		{"past the end", 50, 10, "", 11, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk, err := spool.Read(id, StreamStdout, tt.offset, tt.length)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if chunk.Content != tt.want || chunk.NextOffset != tt.next || chunk.EOF != tt.eof || chunk.TotalBytes != int64(len(content)) {
+				t.Errorf("Read(%d, %d) = %+v, want %q next %d eof %v", tt.offset, tt.length, chunk, tt.want, tt.next, tt.eof)
+			}
+		})
+	}
+}
+
+func TestOutputSpoolReadCapsLength(t *testing.T) {
+	spool := NewOutputSpool()
+	defer spool.Close()
+
+	capture := newOutputCapture(16, spool, nil)
+	capture.stdout.Write(bytes.Repeat([]byte("x"), maxOutputChunk+10))
+	_, _, truncation := capture.finish()
+
+	chunk, err := spool.Read(truncation.OutputID, StreamStdout, 0, 4*maxOutputChunk)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(chunk.Content) != maxOutputChunk || chunk.NextOffset != maxOutputChunk || chunk.EOF {
+		t.Errorf("Read returned %d bytes, next %d, eof %v; want %d bytes and more to come", len(chunk.Content), chunk.NextOffset, chunk.EOF, maxOutputChunk)
+	}
+
+	chunk, err = spool.Read(truncation.OutputID, StreamStdout, chunk.NextOffset, 4*maxOutputChunk)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(chunk.Content) != 10 || !chunk.EOF {
+		t.Errorf("second Read returned %d bytes, eof %v; want the last 10", len(chunk.Content), chunk.EOF)
+	}
+}
@@ -0,0 +1,863 @@
+package runners
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PatchLine struct {
+	Op   byte
+	Text string
+}
+
+type PatchHunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []PatchLine
+	OldNoEOL bool
+	NewNoEOL bool
+}
+
+type FilePatch struct {
+	OldPath  string
+	NewPath  string
+	IsNew    bool
+	IsDelete bool
+	IsRename bool
+	NewMode  os.FileMode
+	Hunks    []PatchHunk
+}
+
+func (p FilePatch) Operation() string {
+	switch {
+	case p.IsNew:
+		return "create"
+	case p.IsDelete:
+		return "delete"
+	case p.IsRename:
+		return "rename"
+	default:
+		return "modify"
+	}
+}
+
+func (p FilePatch) Path() string {
+	if p.IsDelete {
+		return p.OldPath
+	}
+	return p.NewPath
+}
+
+// ParsePatch parses plain unified diffs as well as git-style diffs, including
+// renames, new files and deletions. Only git's rename headers make a rename;
+// a plain diff between two names modifies one of them, see patchSide. strip removes that many leading path
+// components from every path; a negative value strips the a/ and b/ prefixes
+// git adds, when present.
+func ParsePatch(patch string, strip int) ([]FilePatch, error) {
+	var files []FilePatch
+	var current *FilePatch
+	gitStyle := false
+
+	flush := func() {
+		if current != nil {
+			files = append(files, *current)
+			current = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(patch))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flush()
+			gitStyle = true
+			oldPath, newPath := parseGitDiffHeader(strings.TrimPrefix(line, "diff --git "))
+			current = &FilePatch{OldPath: oldPath, NewPath: newPath}
+
+		case strings.HasPrefix(line, "GIT binary patch") || strings.HasPrefix(line, "Binary files "):
+			return nil, fmt.Errorf("binary patches are not supported")
+
+		case current != nil && strings.HasPrefix(line, "new file mode "):
+			current.IsNew = true
+			current.NewMode = parseGitMode(strings.TrimPrefix(line, "new file mode "))
+
+		case current != nil && strings.HasPrefix(line, "new mode "):
+			current.NewMode = parseGitMode(strings.TrimPrefix(line, "new mode "))
+
+		case current != nil && strings.HasPrefix(line, "deleted file mode "):
+			current.IsDelete = true
+
+		case current != nil && strings.HasPrefix(line, "rename from "):
+			prefix := ""
+			if strings.HasPrefix(current.OldPath, "a/") {
+				prefix = "a/"
+			}
+			current.IsRename = true
+			current.OldPath = prefix + unquotePatchPath(strings.TrimPrefix(line, "rename from "))
+
+		case current != nil && strings.HasPrefix(line, "rename to "):
+			prefix := ""
+			if strings.HasPrefix(current.NewPath, "b/") {
+				prefix = "b/"
+			}
+			current.IsRename = true
+			current.NewPath = prefix + unquotePatchPath(strings.TrimPrefix(line, "rename to "))
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldPath := parsePatchFilePath(strings.TrimPrefix(line, "--- "))
+			newPath := parsePatchFilePath(strings.TrimPrefix(lines[i+1], "+++ "))
+			i++
+
+			if current == nil || len(current.Hunks) > 0 {
+				flush()
+				current = &FilePatch{}
+			}
+			if oldPath == "/dev/null" {
+				current.IsNew = true
+			} else if !current.IsRename || current.OldPath == "" {
+				current.OldPath = oldPath
+			}
+			if newPath == "/dev/null" {
+				current.IsDelete = true
+			} else if !current.IsRename || current.NewPath == "" {
+				current.NewPath = newPath
+			}
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: hunk header without a file header", i+1)
+			}
+			hunk, consumed, err := parseHunk(lines[i:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current.Hunks = append(current.Hunks, hunk)
+			i += consumed - 1
+		}
+	}
+	flush()
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file changes found in patch")
+	}
+
+	for i := range files {
+		if files[i].IsNew {
+			files[i].OldPath = ""
+		}
+		if files[i].IsDelete {
+			files[i].NewPath = ""
+		}
+		files[i].OldPath = stripPatchPath(files[i].OldPath, strip, gitStyle, "a/")
+		files[i].NewPath = stripPatchPath(files[i].NewPath, strip, gitStyle, "b/")
+		if files[i].OldPath == "" && files[i].NewPath == "" {
+			return nil, fmt.Errorf("file patch %d has no path", i+1)
+		}
+	}
+
+	return files, nil
+}
+
+func parseHunk(lines []string) (PatchHunk, int, error) {
+	var hunk PatchHunk
+
+	header := lines[0]
+	end := strings.Index(header[3:], " @@")
+	if end < 0 {
+		return hunk, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+	ranges := strings.Fields(header[3 : 3+end])
+	if len(ranges) != 2 || !strings.HasPrefix(ranges[0], "-") || !strings.HasPrefix(ranges[1], "+") {
+		return hunk, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseHunkRange(ranges[0][1:]); err != nil {
+		return hunk, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseHunkRange(ranges[1][1:]); err != nil {
+		return hunk, 0, fmt.Errorf("malformed hunk header: %s", header)
+	}
+
+	oldSeen, newSeen := 0, 0
+	i := 1
+	var last byte
+	for ; i < len(lines) && (oldSeen < hunk.OldLines || newSeen < hunk.NewLines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, `\`) {
+			markNoEOL(&hunk, last)
+			continue
+		}
+
+		op := byte(' ')
+		text := ""
+		if line != "" {
+			op = line[0]
+			text = line[1:]
+		}
+
+		switch op {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		default:
+			return hunk, 0, fmt.Errorf("unexpected line in hunk: %q", line)
+		}
+		last = op
+		hunk.Lines = append(hunk.Lines, PatchLine{Op: op, Text: text})
+	}
+
+	if oldSeen != hunk.OldLines || newSeen != hunk.NewLines {
+		return hunk, 0, fmt.Errorf("hunk is truncated: expected -%d +%d lines, got -%d +%d", hunk.OldLines, hunk.NewLines, oldSeen, newSeen)
+	}
+
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		markNoEOL(&hunk, last)
+		i++
+	}
+
+	return hunk, i, nil
+}
+
+func markNoEOL(hunk *PatchHunk, op byte) {
+	switch op {
+	case '-':
+		hunk.OldNoEOL = true
+	case '+':
+		hunk.NewNoEOL = true
+	case ' ':
+		hunk.OldNoEOL = true
+		hunk.NewNoEOL = true
+	}
+}
+
+func parseHunkRange(value string) (int, int, error) {
+	start, count, found := strings.Cut(value, ",")
+	s, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return s, 1, nil
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, 0, err
+	}
+	return s, c, nil
+}
+
+func parseGitDiffHeader(value string) (string, string) {
+	if strings.HasPrefix(value, `"`) {
+		if oldPath, rest, ok := cutQuoted(value); ok {
+			return oldPath, unquotePatchPath(strings.TrimSpace(rest))
+		}
+	}
+	if idx := strings.LastIndex(value, " b/"); idx >= 0 {
+		return value[:idx], value[idx+1:]
+	}
+	if oldPath, newPath, ok := strings.Cut(value, " "); ok {
+		return oldPath, newPath
+	}
+	return value, value
+}
+
+func cutQuoted(value string) (string, string, bool) {
+	for i := 1; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == '"' {
+			unquoted, err := strconv.Unquote(value[:i+1])
+			if err != nil {
+				return "", "", false
+			}
+			return unquoted, value[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+func parsePatchFilePath(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		if path, _, ok := cutQuoted(value); ok {
+			return path
+		}
+	}
+	if idx := strings.Index(value, "\t"); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
+
+func unquotePatchPath(value string) string {
+	if strings.HasPrefix(value, `"`) {
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	}
+	return value
+}
+
+func parseGitMode(value string) os.FileMode {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0
+	}
+	return os.FileMode(mode) & os.ModePerm
+}
+
+func stripPatchPath(path string, strip int, gitStyle bool, gitPrefix string) string {
+	if path == "" || path == "/dev/null" {
+		return ""
+	}
+	if strip < 0 {
+		if gitStyle || strings.HasPrefix(path, gitPrefix) {
+			return strings.TrimPrefix(path, gitPrefix)
+		}
+		return path
+	}
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	if strip >= len(parts) {
+		return parts[len(parts)-1]
+	}
+	return strings.Join(parts[strip:], "/")
+}
+
+type PatchHunkResult struct {
+	Index   int
+	Applied bool
+	Offset  int
+	Fuzz    int
+	Error   string
+}
+
+// ApplyHunks applies hunks in order to content. A hunk that does not match at
+// its recorded position is searched for nearby, and up to maxFuzz context lines
+// may be ignored at either end of it, the way patch(1) does.
+func ApplyHunks(content string, hunks []PatchHunk, maxFuzz int) (string, []PatchHunkResult, bool) {
+	lines, noEOL := splitPatchContent(content)
+	crlf := len(lines) > 0 && strings.HasSuffix(lines[0], "\r")
+
+	results := make([]PatchHunkResult, len(hunks))
+	ok := true
+	delta := 0
+	minPos := 0
+
+	for idx, hunk := range hunks {
+		results[idx] = PatchHunkResult{Index: idx}
+
+		applied := false
+		for fuzz := 0; fuzz <= maxFuzz && !applied; fuzz++ {
+			lead, trail, usable := hunkContextTrim(hunk, fuzz)
+			if !usable {
+				break
+			}
+			body := hunk.Lines[lead : len(hunk.Lines)-trail]
+
+			var oldLines []string
+			for _, l := range body {
+				if l.Op != '+' {
+					oldLines = append(oldLines, l.Text)
+				}
+			}
+
+			expected := hunk.OldStart - 1 + lead + delta
+			if hunk.OldLines == 0 {
+				expected = hunk.OldStart + delta
+			}
+
+			pos, found := findHunkPosition(lines, oldLines, expected, minPos)
+			if !found {
+				continue
+			}
+
+			touchesEOF := pos+len(oldLines) == len(lines)
+
+			var replacement []string
+			cursor := pos
+			for _, l := range body {
+				switch l.Op {
+				case ' ':
+					replacement = append(replacement, lines[cursor])
+					cursor++
+				case '-':
+					cursor++
+				case '+':
+					text := l.Text
+					if crlf && !strings.HasSuffix(text, "\r") {
+						text += "\r"
+					}
+					replacement = append(replacement, text)
+				}
+			}
+
+			updated := make([]string, 0, len(lines)-len(oldLines)+len(replacement))
+			updated = append(updated, lines[:pos]...)
+			updated = append(updated, replacement...)
+			updated = append(updated, lines[pos+len(oldLines):]...)
+			lines = updated
+
+			if touchesEOF && trail == 0 {
+				noEOL = hunk.NewNoEOL
+			}
+
+			results[idx].Applied = true
+			results[idx].Offset = pos - expected
+			results[idx].Fuzz = fuzz
+			delta += len(replacement) - len(oldLines)
+			minPos = pos + len(replacement)
+			applied = true
+		}
+
+		if !applied {
+			results[idx].Error = fmt.Sprintf("hunk #%d (@@ -%d,%d +%d,%d @@) does not match the file content", idx+1, hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+			ok = false
+		}
+	}
+
+	return joinPatchContent(lines, noEOL), results, ok
+}
+
+func hunkContextTrim(hunk PatchHunk, fuzz int) (int, int, bool) {
+	if fuzz == 0 {
+		return 0, 0, true
+	}
+
+	leading := 0
+	for leading < len(hunk.Lines) && hunk.Lines[leading].Op == ' ' {
+		leading++
+	}
+	trailing := 0
+	for trailing < len(hunk.Lines)-leading && hunk.Lines[len(hunk.Lines)-1-trailing].Op == ' ' {
+		trailing++
+	}
+
+	lead := min(fuzz, leading)
+	trail := min(fuzz, trailing)
+	if lead == 0 && trail == 0 {
+		return 0, 0, false
+	}
+
+	for _, l := range hunk.Lines[lead : len(hunk.Lines)-trail] {
+		if l.Op != '+' {
+			return lead, trail, true
+		}
+	}
+	return 0, 0, false
+}
+
+func findHunkPosition(lines, oldLines []string, expected, minPos int) (int, bool) {
+	maxPos := len(lines) - len(oldLines)
+	if maxPos < minPos {
+		return 0, false
+	}
+
+	if len(oldLines) == 0 {
+		return max(minPos, min(expected, maxPos)), true
+	}
+
+	for distance := 0; ; distance++ {
+		before, after := expected-distance, expected+distance
+		if before < minPos && after > maxPos {
+			return 0, false
+		}
+		if before >= minPos && before <= maxPos && linesMatch(lines[before:], oldLines) {
+			return before, true
+		}
+		if distance > 0 && after >= minPos && after <= maxPos && linesMatch(lines[after:], oldLines) {
+			return after, true
+		}
+	}
+}
+
+func linesMatch(lines, expected []string) bool {
+	for i, want := range expected {
+		if strings.TrimSuffix(lines[i], "\r") != strings.TrimSuffix(want, "\r") {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPatchContent(content string) ([]string, bool) {
+	if content == "" {
+		return nil, false
+	}
+	if strings.HasSuffix(content, "\n") {
+		return strings.Split(strings.TrimSuffix(content, "\n"), "\n"), false
+	}
+	return strings.Split(content, "\n"), true
+}
+
+func joinPatchContent(lines []string, noEOL bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if !noEOL {
+		content += "\n"
+	}
+	return content
+}
+
+type ApplyPatchInput struct {
+	Patch   string
+	Strip   int
+	Fuzz    int
+	Check   bool
+	Resolve func(path string) (string, error)
+	// Verify, when set, is called once for every existing file the patch
+	// modifies, renames or deletes, before anything is written. An error
+	// fails that file.
+	Verify func(path string, modTime time.Time, content []byte) error
+}
+
+type PatchFileResult struct {
+	Path      string
+	OldPath   string
+	Operation string
+	Hunks     []PatchHunkResult
+	Error     string
+}
+
+type ApplyPatchResult struct {
+	Applied bool
+	Files   []PatchFileResult
+}
+
+type patchTarget struct {
+	exists  bool
+	content string
+	mode    os.FileMode
+	format  TextFormat
+	raw     []byte
+
+	modTime  time.Time
+	original []byte
+	verified bool
+}
+
+func (r *FileRunner) ApplyPatch(ctx context.Context, input ApplyPatchInput) (ApplyPatchResult, error) {
+	patches, err := ParsePatch(input.Patch, input.Strip)
+	if err != nil {
+		return ApplyPatchResult{}, err
+	}
+
+	resolve := input.Resolve
+	if resolve == nil {
+		resolve = filepath.Abs
+	}
+
+	pending := make(map[string]*patchTarget)
+	var order []string
+
+	load := func(path string) (*patchTarget, error) {
+		if target, ok := pending[path]; ok {
+			return target, nil
+		}
+		target := &patchTarget{mode: 0644, format: DefaultTextFormat()}
+		info, err := os.Stat(path)
+		if err == nil {
+			if info.IsDir() {
+				return nil, fmt.Errorf("path is a directory: %s", path)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			target.exists = true
+			target.content, target.format = DecodeText(data)
+			target.mode = fileMode(info)
+			target.modTime = info.ModTime()
+			target.original = data
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+		pending[path] = target
+		order = append(order, path)
+		return target, nil
+	}
+
+	result := ApplyPatchResult{Files: make([]PatchFileResult, len(patches))}
+	failed := false
+
+	for i, patch := range patches {
+		if ctx.Err() != nil {
+			return ApplyPatchResult{}, ctx.Err()
+		}
+
+		fileResult := &result.Files[i]
+		fileResult.Operation = patch.Operation()
+
+		fail := func(format string, args ...interface{}) {
+			fileResult.Error = fmt.Sprintf(format, args...)
+			failed = true
+		}
+
+		var oldPath, newPath string
+		if patch.OldPath != "" {
+			if oldPath, err = resolve(patch.OldPath); err != nil {
+				fileResult.Path = patch.Path()
+				fail("%v", err)
+				continue
+			}
+		}
+		if patch.NewPath != "" {
+			if newPath, err = resolve(patch.NewPath); err != nil {
+				fileResult.Path = patch.Path()
+				fail("%v", err)
+				continue
+			}
+		}
+
+		if !patch.IsNew && !patch.IsDelete && !patch.IsRename && oldPath != newPath {
+			exists := func(path string) bool {
+				if target, ok := pending[path]; ok {
+					return target.exists
+				}
+				_, err := os.Stat(path)
+				return err == nil
+			}
+			if patchSide(patch, oldPath, newPath, exists) == patch.OldPath {
+				newPath = oldPath
+			} else {
+				oldPath = newPath
+			}
+		}
+
+		fileResult.Path = newPath
+		if patch.IsDelete {
+			fileResult.Path = oldPath
+		}
+		if patch.IsRename {
+			fileResult.OldPath = oldPath
+		}
+
+		source := &patchTarget{}
+		if !patch.IsNew {
+			if source, err = load(oldPath); err != nil {
+				fail("%v", err)
+				continue
+			}
+			if !source.exists {
+				fail("file does not exist: %s", oldPath)
+				continue
+			}
+			if input.Verify != nil && !source.verified && source.original != nil {
+				if err := input.Verify(oldPath, source.modTime, source.original); err != nil {
+					fail("%v", err)
+					continue
+				}
+				source.verified = true
+			}
+		}
+
+		dest := source
+		if patch.IsNew || patch.IsRename {
+			if dest, err = load(newPath); err != nil {
+				fail("%v", err)
+				continue
+			}
+			if dest.exists {
+				fail("target already exists: %s", newPath)
+				continue
+			}
+		}
+
+		content, hunks, ok := ApplyHunks(source.content, patch.Hunks, input.Fuzz)
+		fileResult.Hunks = hunks
+		if !ok {
+			fail("%d of %d hunk(s) failed to apply", countFailedHunks(hunks), len(hunks))
+			continue
+		}
+
+		switch {
+		case patch.IsDelete:
+			if content != "" {
+				fail("file is not empty after applying deletion patch: %s", oldPath)
+				continue
+			}
+			source.exists = false
+			source.content = ""
+		case patch.IsRename:
+			dest.exists = true
+			dest.content = content
+			dest.mode = source.mode
+			dest.format = source.format
+			source.exists = false
+			source.content = ""
+		default:
+			dest.exists = true
+			dest.content = content
+		}
+
+		if patch.NewMode != 0 && !patch.IsDelete {
+			dest.mode = patch.NewMode
+		}
+	}
+
+	for i, patch := range patches {
+		target := pending[result.Files[i].Path]
+		if target == nil || !target.exists {
+			continue
+		}
+		encoded, err := EncodeText(target.content, target.format)
+		if err != nil {
+			result.Files[i].Error = fmt.Sprintf("failed to encode %s as %s: %v", patch.Path(), target.format.Encoding, err)
+			failed = true
+			continue
+		}
+		target.raw = encoded
+	}
+
+	if failed || input.Check {
+		return result, nil
+	}
+
+	originals := make(map[string]*patchTarget, len(order))
+	for _, path := range order {
+		original := &patchTarget{}
+		if info, err := os.Stat(path); err == nil {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return ApplyPatchResult{}, fmt.Errorf("failed to snapshot %s: %w", path, err)
+			}
+			original.exists = true
+			original.raw = data
+			original.mode = fileMode(info)
+		}
+		originals[path] = original
+	}
+
+	var written, createdDirs []string
+	for _, path := range order {
+		if err := writePatchTarget(path, pending[path], &createdDirs); err != nil {
+			written = append(written, path)
+			if rollbackErr := rollbackPatch(written, originals, createdDirs); rollbackErr != nil {
+				return ApplyPatchResult{}, fmt.Errorf("failed to write %s: %w (rollback also failed: %v)", path, err, rollbackErr)
+			}
+			return ApplyPatchResult{}, fmt.Errorf("failed to write %s, all changes were rolled back: %w", path, err)
+		}
+		written = append(written, path)
+	}
+
+	result.Applied = true
+	return result, nil
+}
+
+// patchSide picks the file a plain diff between two different names modifies,
+// the way patch(1) does: of the names that exist, the one with the fewest
+// path components, then the shortest base name, then the shortest name.
+// Without either, the new name is reported as missing.
+func patchSide(patch FilePatch, oldPath, newPath string, exists func(string) bool) string {
+	var best string
+	for _, candidate := range []struct{ name, path string }{{patch.NewPath, newPath}, {patch.OldPath, oldPath}} {
+		if !exists(candidate.path) {
+			continue
+		}
+		if best == "" || patchNameLess(candidate.name, best) {
+			best = candidate.name
+		}
+	}
+	if best == "" {
+		return patch.NewPath
+	}
+	return best
+}
+
+func patchNameLess(a, b string) bool {
+	if ca, cb := strings.Count(filepath.ToSlash(a), "/"), strings.Count(filepath.ToSlash(b), "/"); ca != cb {
+		return ca < cb
+	}
+	if ba, bb := len(filepath.Base(a)), len(filepath.Base(b)); ba != bb {
+		return ba < bb
+	}
+	return len(a) < len(b)
+}
+
+// writePatchTarget writes or removes path. Directories it has to create are
+// appended to createdDirs, parents first, when createdDirs is not nil.
+func writePatchTarget(path string, target *patchTarget, createdDirs *[]string) error {
+	if !target.exists {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	dir := filepath.Dir(path)
+	var missing []string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		missing = append([]string{current}, missing...)
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+	if createdDirs != nil {
+		*createdDirs = append(*createdDirs, missing...)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeAtomic(path, bytes.NewReader(target.raw), target.mode, false)
+}
+
+// rollbackPatch restores the written paths to their originals and then
+// removes the directories the patch created, deepest first.
+func rollbackPatch(paths []string, originals map[string]*patchTarget, createdDirs []string) error {
+	var errs []error
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := writePatchTarget(paths[i], originals[paths[i]], nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i := len(createdDirs) - 1; i >= 0; i-- {
+		if err := os.Remove(createdDirs[i]); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func countFailedHunks(hunks []PatchHunkResult) int {
+	count := 0
+	for _, hunk := range hunks {
+		if !hunk.Applied {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,376 @@
+package runners
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		strip int
+		want  []FilePatch
+	}{
+		{
+			name:  "plain unified diff",
+			patch: "--- a.txt\n+++ a.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "a.txt", NewPath: "a.txt"}},
+		},
+		{
+			name:  "git prefixes are stripped",
+			patch: "diff --git a/dir/a.txt b/dir/a.txt\nindex 1..2 100644\n--- a/dir/a.txt\n+++ b/dir/a.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "dir/a.txt", NewPath: "dir/a.txt"}},
+		},
+		{
+			name:  "explicit strip",
+			patch: "--- x/dir/a.txt\t2024-01-01\n+++ y/dir/a.txt\t2024-01-02\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: 1,
+			want:  []FilePatch{{OldPath: "dir/a.txt", NewPath: "dir/a.txt"}},
+		},
+		{
+			name:  "quoted paths",
+			patch: "diff --git \"a/sp ace\\t.txt\" \"b/sp ace\\t.txt\"\n--- \"a/sp ace\\t.txt\"\n+++ \"b/sp ace\\t.txt\"\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "sp ace\t.txt", NewPath: "sp ace\t.txt"}},
+		},
+		{
+			name:  "git rename headers",
+			patch: "diff --git a/old.txt b/new.txt\nsimilarity index 90%\nrename from old.txt\nrename to new.txt\n--- a/old.txt\n+++ b/new.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "old.txt", NewPath: "new.txt", IsRename: true}},
+		},
+		{
+			name:  "quoted rename headers",
+			patch: "diff --git \"a/o ld.txt\" \"b/n ew.txt\"\nsimilarity index 100%\nrename from \"o ld.txt\"\nrename to \"n ew.txt\"\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "o ld.txt", NewPath: "n ew.txt", IsRename: true}},
+		},
+		{
+			name:  "different names without rename headers",
+			patch: "--- p.txt.orig\n+++ p.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			strip: -1,
+			want:  []FilePatch{{OldPath: "p.txt.orig", NewPath: "p.txt"}},
+		},
+		{
+			name:  "new and deleted files",
+			patch: "diff --git a/n.txt b/n.txt\nnew file mode 100755\n--- /dev/null\n+++ b/n.txt\n@@ -0,0 +1 @@\n+n\ndiff --git a/d.txt b/d.txt\ndeleted file mode 100644\n--- a/d.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-d\n",
+			strip: -1,
+			want: []FilePatch{
+				{NewPath: "n.txt", IsNew: true, NewMode: 0755},
+				{OldPath: "d.txt", IsDelete: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch(tt.patch, tt.strip)
+			if err != nil {
+				t.Fatalf("ParsePatch: %v", err)
+			}
+			for i := range files {
+				files[i].Hunks = nil
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("ParsePatch = %+v, want %+v", files, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePatchNoNewline(t *testing.T) {
+	patch := "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"
+	files, err := ParsePatch(patch, -1)
+	if err != nil {
+		t.Fatalf("ParsePatch: %v", err)
+	}
+	hunk := files[0].Hunks[0]
+	if !hunk.OldNoEOL || !hunk.NewNoEOL {
+		t.Errorf("OldNoEOL, NewNoEOL = %v, %v, want true, true", hunk.OldNoEOL, hunk.NewNoEOL)
+	}
+	if len(hunk.Lines) != 3 {
+		t.Errorf("got %d hunk lines, want 3", len(hunk.Lines))
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":             "",
+		"hunk without file": "@@ -1 +1 @@\n-a\n+b\n",
+		"truncated hunk":    "--- a.txt\n+++ a.txt\n@@ -1,3 +1,3 @@\n a\n-b\n",
+		"malformed header":  "--- a.txt\n+++ a.txt\n@@ -x +1 @@\n-a\n+b\n",
+		"binary":            "diff --git a/b.bin b/b.bin\nGIT binary patch\nliteral 1\n",
+	}
+	for name, patch := range tests {
+		if _, err := ParsePatch(patch, -1); err == nil {
+			t.Errorf("%s: ParsePatch succeeded, want an error", name)
+		}
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		patch      string
+		fuzz       int
+		want       string
+		wantOffset int
+		wantFuzz   int
+		wantFailed bool
+	}{
+		{
+			name:    "exact position",
+			content: "a\nb\nc\n",
+			patch:   "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:       "offset",
+			content:    "x\ny\na\nb\nc\n",
+			patch:      "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:       "x\ny\na\nB\nc\n",
+			wantOffset: 2,
+		},
+		{
+			name:       "context mismatch without fuzz",
+			content:    "a\nb\nC\n",
+			patch:      "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			wantFailed: true,
+		},
+		{
+			name:     "fuzz ignores the outer context line",
+			content:  "a\nb\nC\n",
+			patch:    "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			fuzz:     1,
+			want:     "a\nB\nC\n",
+			wantFuzz: 1,
+		},
+		{
+			name:    "adds a missing final newline",
+			content: "a\nb",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+			want:    "a\nb\n",
+		},
+		{
+			name:    "removes the final newline",
+			content: "a\nb\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+			want:    "a\nb",
+		},
+		{
+			name:    "keeps CRLF line endings",
+			content: "a\r\nb\r\n",
+			patch:   "@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want:    "a\r\nB\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ParsePatch("--- f\n+++ f\n"+tt.patch, -1)
+			if err != nil {
+				t.Fatalf("ParsePatch: %v", err)
+			}
+			got, results, ok := ApplyHunks(tt.content, files[0].Hunks, tt.fuzz)
+			if ok == tt.wantFailed {
+				t.Fatalf("ApplyHunks ok = %v, want %v (results %+v)", ok, !tt.wantFailed, results)
+			}
+			if tt.wantFailed {
+				if results[0].Applied || results[0].Error == "" {
+					t.Errorf("result = %+v, want a failed hunk with an error", results[0])
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+			if results[0].Offset != tt.wantOffset || results[0].Fuzz != tt.wantFuzz {
+				t.Errorf("offset, fuzz = %d, %d, want %d, %d", results[0].Offset, results[0].Fuzz, tt.wantOffset, tt.wantFuzz)
+			}
+		})
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		patch   string
+		want    map[string]string
+		missing []string
+	}{
+		{
+			name:    "plain diff patches the existing old name",
+			files:   map[string]string{"p.txt.orig": "a\nb\n"},
+			patch:   "--- p.txt.orig\n+++ p.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			want:    map[string]string{"p.txt.orig": "a\nc\n"},
+			missing: []string{"p.txt"},
+		},
+		{
+			name:  "plain diff prefers the shorter existing name",
+			files: map[string]string{"p.txt.orig": "a\nb\n", "p.txt": "a\nb\n"},
+			patch: "--- p.txt.orig\n+++ p.txt\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+			want:  map[string]string{"p.txt": "a\nc\n", "p.txt.orig": "a\nb\n"},
+		},
+		{
+			name:    "git rename",
+			files:   map[string]string{"old.txt": "a\n"},
+			patch:   "diff --git a/old.txt b/sub/new.txt\nsimilarity index 50%\nrename from old.txt\nrename to sub/new.txt\n--- a/old.txt\n+++ b/sub/new.txt\n@@ -1 +1 @@\n-a\n+b\n",
+			want:    map[string]string{"sub/new.txt": "b\n"},
+			missing: []string{"old.txt"},
+		},
+		{
+			name:    "create and delete",
+			files:   map[string]string{"d.txt": "d\n"},
+			patch:   "--- /dev/null\n+++ b/n/n.txt\n@@ -0,0 +1 @@\n+n\n--- a/d.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-d\n",
+			want:    map[string]string{"n/n.txt": "n\n"},
+			missing: []string{"d.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := NewFileRunner().ApplyPatch(context.Background(), ApplyPatchInput{
+				Patch: tt.patch,
+				Strip: -1,
+				Resolve: func(path string) (string, error) {
+					return filepath.Join(dir, path), nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("ApplyPatch: %v", err)
+			}
+			if !result.Applied {
+				t.Fatalf("patch not applied: %+v", result.Files)
+			}
+
+			for name, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Errorf("%s: %v", name, err)
+				} else if string(got) != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			for _, name := range tt.missing {
+				if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s exists, want it missing", name)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPatchVerify(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var verified []string
+	result, err := NewFileRunner().ApplyPatch(context.Background(), ApplyPatchInput{
+		Patch:   "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-a\n+b\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+n\n",
+		Strip:   -1,
+		Resolve: func(p string) (string, error) { return filepath.Join(dir, p), nil },
+		Verify: func(p string, _ time.Time, content []byte) error {
+			verified = append(verified, filepath.Base(p)+"="+string(content))
+			return fmt.Errorf("not read: %s", filepath.Base(p))
+		},
+	})
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	if result.Applied {
+		t.Error("patch applied although Verify failed")
+	}
+	if want := []string{"a.txt=a\n"}; !reflect.DeepEqual(verified, want) {
+		t.Errorf("verified %q, want %q", verified, want)
+	}
+	if !strings.Contains(result.Files[0].Error, "not read: a.txt") {
+		t.Errorf("error = %q, want the Verify error", result.Files[0].Error)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "a\n" {
+		t.Errorf("a.txt = %q, want it unchanged", got)
+	}
+}
+
+func TestApplyPatchKeepsSpecialModeBits(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tool.sh")
+	if err := os.WriteFile(path, []byte("a\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mode := os.FileMode(0755) | os.ModeSetgid
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || fileMode(info) != mode {
+		t.Skip("setgid bit is not kept on this file system")
+	}
+
+	_, err := NewFileRunner().ApplyPatch(context.Background(), ApplyPatchInput{
+		Patch:   "--- a/tool.sh\n+++ b/tool.sh\n@@ -1 +1 @@\n-a\n+b\n",
+		Strip:   -1,
+		Resolve: func(p string) (string, error) { return filepath.Join(dir, p), nil },
+	})
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fileMode(info); got != mode {
+		t.Errorf("mode = %v, want %v", got, mode)
+	}
+}
+
+func TestRollbackPatchRemovesCreatedDirs(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{
+		filepath.Join(dir, "a", "b", "one.txt"),
+		filepath.Join(dir, "a", "b", "c", "two.txt"),
+	}
+
+	var created []string
+	originals := make(map[string]*patchTarget)
+	for _, path := range paths {
+		if err := writePatchTarget(path, &patchTarget{exists: true, raw: []byte("x"), mode: 0644}, &created); err != nil {
+			t.Fatalf("writePatchTarget: %v", err)
+		}
+		originals[path] = &patchTarget{}
+	}
+	want := []string{filepath.Join(dir, "a"), filepath.Join(dir, "a", "b"), filepath.Join(dir, "a", "b", "c")}
+	if !reflect.DeepEqual(created, want) {
+		t.Errorf("created = %q, want %q", created, want)
+	}
+
+	if err := rollbackPatch(paths, originals, created); err != nil {
+		t.Fatalf("rollbackPatch: %v", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%d entries left after rollback, want none", len(entries))
+	}
+}
@@ -0,0 +1,293 @@
+package runners
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+)
+
+const (
+	PolicyAllow   = "allow"
+	PolicyDeny    = "deny"
+	PolicyConfirm = "confirm"
+
+	// argsWildcard matches any number of arguments, including none.
+	argsWildcard = "**"
+)
+
+type PolicyDecision struct {
+	Action string `json:"action"`
+	// Rule is the name (or index, for unnamed rules) of the matching rule;
+	// empty when no rule matched and the default action applied.
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type policyRule struct {
+	name    string
+	action  string
+	reason  string
+	command *regexp.Regexp
+	args    []*regexp.Regexp
+	anyArgs bool
+}
+
+// CommandPolicy decides whether the run tool may execute a command. Rules are
+// checked in order and the first whose command and argv patterns match wins.
+type CommandPolicy struct {
+	defaultAction string
+	rules         []policyRule
+}
+
+func NewCommandPolicy(cfg config.RunPolicyConfig) (*CommandPolicy, error) {
+	policy := &CommandPolicy{defaultAction: PolicyAllow}
+	if cfg.Default != "" {
+		if !validPolicyAction(cfg.Default) {
+			return nil, fmt.Errorf("invalid run.policy.default: %s (expected allow, deny or confirm)", cfg.Default)
+		}
+		policy.defaultAction = cfg.Default
+	}
+
+	for i, rule := range cfg.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if !validPolicyAction(rule.Action) {
+			return nil, fmt.Errorf("run policy rule %s: invalid action %q (expected allow, deny or confirm)", name, rule.Action)
+		}
+		if rule.Command == "" {
+			return nil, fmt.Errorf("run policy rule %s: command is required", name)
+		}
+
+		compiled := policyRule{
+			name:    name,
+			action:  rule.Action,
+			reason:  rule.Reason,
+			command: wildcardPattern(rule.Command),
+			anyArgs: rule.Args == nil,
+		}
+		for _, arg := range rule.Args {
+			if arg == argsWildcard {
+				compiled.args = append(compiled.args, nil)
+				continue
+			}
+			compiled.args = append(compiled.args, wildcardPattern(arg))
+		}
+		policy.rules = append(policy.rules, compiled)
+	}
+
+	return policy, nil
+}
+
+// Evaluate decides on a single argv as given. Deny and confirm rules also
+// match after leading options, so `git -c k=v push` cannot slip past a rule
+// for ["push", "**"]; allow rules only match the argv as given.
+func (p *CommandPolicy) Evaluate(command string, args []string) PolicyDecision {
+	for _, rule := range p.rules {
+		if !rule.command.MatchString(command) && !rule.command.MatchString(filepath.Base(command)) {
+			continue
+		}
+		if !rule.anyArgs && !rule.matchArgs(args) {
+			continue
+		}
+		return PolicyDecision{Action: rule.action, Rule: rule.name, Reason: rule.reason}
+	}
+	return PolicyDecision{Action: p.defaultAction}
+}
+
+func (r policyRule) matchArgs(args []string) bool {
+	if matchArgs(r.args, args) {
+		return true
+	}
+	if r.action == PolicyAllow {
+		return false
+	}
+	for _, start := range afterOptions(args) {
+		if matchArgs(r.args, args[start:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// afterOptions returns every index at which args could continue after a run
+// of leading options, such as the subcommand after git's global options.
+// Whether an option takes a value is unknown, so a word after an option
+// without "=" is tried both as its value and as the next argument.
+func afterOptions(args []string) []int {
+	reachable := make([]bool, len(args)+1)
+	reachable[0] = true
+	var starts []int
+	for i := 0; i < len(args); i++ {
+		if !reachable[i] || !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		reachable[i+1] = true
+		if !strings.Contains(args[i], "=") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			reachable[i+2] = true
+		}
+	}
+	for i := 1; i < len(reachable); i++ {
+		if reachable[i] {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+// EvaluateCommand decides on a command run directly, without a shell.
+// Wrappers such as env and nohup are looked through, so a rule for the
+// wrapped command applies too; commands that run others in ways argv does
+// not show (sudo, xargs, timeout, ...) are escalated as for scripts.
+func (p *CommandPolicy) EvaluateCommand(command string, args []string) PolicyDecision {
+	argv := append([]string{command}, args...)
+	inner, ok := unwrapCommand(argv)
+	if ok && len(inner) == len(argv) {
+		return p.Evaluate(command, args)
+	}
+
+	var commands [][]string
+	if ok && len(inner) > 0 {
+		commands = append(commands, inner)
+	}
+	const reason = "the command runs another command in a way that cannot be checked (such as sudo, xargs or timeout)"
+	return p.evaluateParts(command, args, commands, ok, "runs", reason)
+}
+
+// EvaluateScript decides on a script run as `shell -c script`. Every command
+// in the script is checked on its own and the most restrictive decision wins,
+// so deny rules also apply inside shell runs; rules that match the shell
+// invocation itself still count. When the script hides some of its commands
+// (see splitShellScript) it is denied under a deny default and needs
+// confirmation if any rule denies or confirms.
+func (p *CommandPolicy) EvaluateScript(shell string, args []string, script string) PolicyDecision {
+	commands, ok := splitShellScript(script)
+	const reason = "the script uses constructs (such as $(...), subshells, eval or sudo) that hide which commands it runs"
+	return p.evaluateParts(shell, args, commands, ok, "script runs", reason)
+}
+
+// evaluateParts combines the decision on an invocation, when a rule matched
+// it, with those on the commands it runs; the most restrictive wins. When
+// decidable is false some commands are unknown, which is denied under a deny
+// default and needs confirmation if any rule denies or confirms.
+func (p *CommandPolicy) evaluateParts(command string, args []string, commands [][]string, decidable bool, label, reason string) PolicyDecision {
+	var decisions []PolicyDecision
+	if decision := p.Evaluate(command, args); decision.Rule != "" {
+		decisions = append(decisions, decision)
+	}
+
+	for _, argv := range commands {
+		decision := p.Evaluate(argv[0], argv[1:])
+		if decision.Action != PolicyAllow {
+			part := formatArgv(argv)
+			if decision.Reason == "" {
+				decision.Reason = fmt.Sprintf("%s %s", label, part)
+			} else {
+				decision.Reason = fmt.Sprintf("%s (%s %s)", decision.Reason, label, part)
+			}
+		}
+		decisions = append(decisions, decision)
+	}
+
+	if !decidable {
+		switch {
+		case p.defaultAction == PolicyDeny:
+			decisions = append(decisions, PolicyDecision{Action: PolicyDeny, Reason: reason})
+		case p.restrictive():
+			decisions = append(decisions, PolicyDecision{Action: PolicyConfirm, Reason: reason})
+		}
+	}
+
+	if len(decisions) == 0 {
+		return PolicyDecision{Action: p.defaultAction}
+	}
+	result := decisions[0]
+	for _, decision := range decisions[1:] {
+		if policyRank(decision.Action) > policyRank(result.Action) {
+			result = decision
+		}
+	}
+	return result
+}
+
+func (p *CommandPolicy) restrictive() bool {
+	if p.defaultAction != PolicyAllow {
+		return true
+	}
+	for _, rule := range p.rules {
+		if rule.action != PolicyAllow {
+			return true
+		}
+	}
+	return false
+}
+
+func policyRank(action string) int {
+	switch action {
+	case PolicyDeny:
+		return 2
+	case PolicyConfirm:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func formatArgv(argv []string) string {
+	parts := make([]string, len(argv))
+	for i, arg := range argv {
+		parts[i] = arg
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'") {
+			parts[i] = strconv.Quote(arg)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func validPolicyAction(action string) bool {
+	return action == PolicyAllow || action == PolicyDeny || action == PolicyConfirm
+}
+
+// matchArgs matches argv against per-argument patterns where a nil pattern
+// stands for "**" and consumes any number of arguments.
+func matchArgs(patterns []*regexp.Regexp, args []string) bool {
+	if len(patterns) == 0 {
+		return len(args) == 0
+	}
+	if patterns[0] == nil {
+		for i := 0; i <= len(args); i++ {
+			if matchArgs(patterns[1:], args[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(args) == 0 || !patterns[0].MatchString(args[0]) {
+		return false
+	}
+	return matchArgs(patterns[1:], args[1:])
+}
+
+// wildcardPattern compiles a pattern where * matches any run of characters
+// (including '/') and ? matches exactly one.
+func wildcardPattern(pattern string) *regexp.Regexp {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.MustCompile(builder.String())
+}
@@ -0,0 +1,152 @@
+package runners
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+)
+
+func newTestPolicy(t *testing.T, defaultAction string, rules ...config.RunPolicyRule) *CommandPolicy {
+	t.Helper()
+	policy, err := NewCommandPolicy(config.RunPolicyConfig{Default: defaultAction, Rules: rules})
+	if err != nil {
+		t.Fatalf("NewCommandPolicy: %v", err)
+	}
+	return policy
+}
+
+func TestNewCommandPolicyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.RunPolicyConfig
+		want string
+	}{
+		{"bad default", config.RunPolicyConfig{Default: "maybe"}, "invalid run.policy.default"},
+		{"bad action", config.RunPolicyConfig{Rules: []config.RunPolicyRule{{Command: "git", Action: "block"}}}, "rule #1: invalid action"},
+		{"no command", config.RunPolicyConfig{Rules: []config.RunPolicyRule{{Name: "push", Action: PolicyDeny}}}, "rule push: command is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewCommandPolicy(tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("NewCommandPolicy error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAfterOptions(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []int
+	}{
+		{[]string{"push"}, nil},
+		{[]string{"--no-pager", "push"}, []int{1, 2}},
+		{[]string{"-c", "k=v", "push"}, []int{1, 2}},
+		{[]string{"--git-dir=x", "push"}, []int{1}},
+		{[]string{"-C", "dir", "-c", "k=v", "push", "-f"}, []int{1, 2, 3, 4}},
+		{[]string{"-v", "-q"}, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		if got := afterOptions(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("afterOptions(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	policy := newTestPolicy(t, PolicyAllow,
+		config.RunPolicyRule{Name: "status", Command: "git", Args: []string{"status", "**"}, Action: PolicyAllow},
+		config.RunPolicyRule{Name: "push", Command: "git", Args: []string{"push", "**"}, Action: PolicyDeny, Reason: "no pushing"},
+		config.RunPolicyRule{Command: "rm", Args: []string{"-rf", "*"}, Action: PolicyConfirm},
+		config.RunPolicyRule{Name: "make", Command: "make", Action: PolicyConfirm},
+	)
+	tests := []struct {
+		command string
+		args    []string
+		action  string
+		rule    string
+	}{
+		{"git", []string{"push"}, PolicyDeny, "push"},
+		{"/usr/bin/git", []string{"push", "origin", "main"}, PolicyDeny, "push"},
+		{"git", []string{"-c", "k=v", "push"}, PolicyDeny, "push"},
+		{"git", []string{"--no-pager", "-C", "dir", "push", "-f"}, PolicyDeny, "push"},
+		{"git", []string{"log", "push"}, PolicyAllow, ""},
+		{"git", []string{"status"}, PolicyAllow, "status"},
+		// Allow rules do not skip options, so the deny rule still applies.
+		{"git", []string{"-c", "status", "push"}, PolicyDeny, "push"},
+		{"rm", []string{"-rf", "build"}, PolicyConfirm, "#3"},
+		{"rm", []string{"-rf", "a", "b"}, PolicyAllow, ""},
+		{"make", nil, PolicyConfirm, "make"},
+		{"make", []string{"-j8", "all"}, PolicyConfirm, "make"},
+	}
+	for _, tt := range tests {
+		got := policy.Evaluate(tt.command, tt.args)
+		if got.Action != tt.action || got.Rule != tt.rule {
+			t.Errorf("Evaluate(%q, %q) = %s (rule %q), want %s (rule %q)", tt.command, tt.args, got.Action, got.Rule, tt.action, tt.rule)
+		}
+	}
+}
+
+func TestEvaluateCommand(t *testing.T) {
+	deny := config.RunPolicyRule{Name: "push", Command: "git", Args: []string{"push", "**"}, Action: PolicyDeny}
+	tests := []struct {
+		name    string
+		policy  *CommandPolicy
+		command string
+		args    []string
+		action  string
+	}{
+		{"plain", newTestPolicy(t, PolicyAllow, deny), "git", []string{"push"}, PolicyDeny},
+		{"env", newTestPolicy(t, PolicyAllow, deny), "env", []string{"A=1", "git", "push"}, PolicyDeny},
+		{"nohup", newTestPolicy(t, PolicyAllow, deny), "nohup", []string{"git", "-c", "k=v", "push"}, PolicyDeny},
+		{"wrapped allowed", newTestPolicy(t, PolicyAllow, deny), "env", []string{"git", "status"}, PolicyAllow},
+		{"opaque under rules", newTestPolicy(t, PolicyAllow, deny), "sudo", []string{"git", "push"}, PolicyConfirm},
+		{"opaque under deny default", newTestPolicy(t, PolicyDeny), "timeout", []string{"5", "ls"}, PolicyDeny},
+		{"opaque without rules", newTestPolicy(t, PolicyAllow), "xargs", []string{"rm"}, PolicyAllow},
+		{"find -exec", newTestPolicy(t, PolicyAllow, deny), "find", []string{".", "-exec", "git", "push", ";"}, PolicyConfirm},
+		{"default deny", newTestPolicy(t, PolicyDeny), "ls", nil, PolicyDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.EvaluateCommand(tt.command, tt.args)
+			if got.Action != tt.action {
+				t.Fatalf("EvaluateCommand(%q, %q) = %+v, want %s", tt.command, tt.args, got, tt.action)
+			}
+		})
+	}
+}
+
+func TestEvaluateScript(t *testing.T) {
+	policy := newTestPolicy(t, PolicyAllow,
+		config.RunPolicyRule{Name: "push", Command: "git", Args: []string{"push", "**"}, Action: PolicyDeny},
+		config.RunPolicyRule{Name: "make", Command: "make", Action: PolicyConfirm},
+	)
+	tests := []struct {
+		script string
+		action string
+		reason string
+	}{
+		{"ls && git status", PolicyAllow, ""},
+		{"make build > out.log 2>&1", PolicyConfirm, "script runs make build"},
+		{"make &> log; git push origin", PolicyDeny, "script runs git push origin"},
+		{"env GIT_TRACE=1 git push", PolicyDeny, "script runs git push"},
+		{"echo $(git push)", PolicyConfirm, "hide which commands it runs"},
+		{"find . -exec git push \\;", PolicyConfirm, "hide which commands it runs"},
+	}
+	for _, tt := range tests {
+		got := policy.EvaluateScript("bash", []string{"-c", tt.script}, tt.script)
+		if got.Action != tt.action || !strings.Contains(got.Reason, tt.reason) {
+			t.Errorf("EvaluateScript(%q) = %+v, want %s with reason containing %q", tt.script, got, tt.action, tt.reason)
+		}
+	}
+
+	shellRule := newTestPolicy(t, PolicyAllow,
+		config.RunPolicyRule{Name: "bash", Command: "bash", Action: PolicyDeny},
+	)
+	if got := shellRule.EvaluateScript("bash", []string{"-c", "ls"}, "ls"); got.Action != PolicyDeny || got.Rule != "bash" {
+		t.Errorf("EvaluateScript with a rule for the shell = %+v, want deny by rule bash", got)
+	}
+}
@@ -0,0 +1,517 @@
+package runners
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProcessRunning  = "running"
+	ProcessExited   = "exited"
+	ProcessSignaled = "signaled"
+
+	DefaultMaxProcesses = 8
+
+	// processBufferLimit caps the output kept in memory per stream; older
+	// bytes are dropped and reported to readers whose cursor falls behind.
+	processBufferLimit = 1 << 20
+	// maxFinishedProcesses bounds how many exited processes stay listed.
+	maxFinishedProcesses = 32
+	// processStopGrace is how long a terminated process gets before it is killed.
+	processStopGrace = 5 * time.Second
+)
+
+type StartProcessInput struct {
+	Command        string
+	Args           []string
+	WorkingDir     string
+	TimeoutSeconds int
+	Stdin          string
+	// Env is the complete environment; nil inherits the server's.
+	Env []string
+	// Owner scopes the process: lookups from a different owner do not see it.
+	Owner     any
+	Isolation *Isolation
+	// Pty runs the process on a pseudo-terminal, which Input writes to.
+	Pty       *PtyOptions
+	StripANSI bool
+}
+
+type ProcessInfo struct {
+	ID          string     `json:"process_id"`
+	PID         int        `json:"pid"`
+	Command     string     `json:"command"`
+	Args        []string   `json:"args,omitempty"`
+	WorkingDir  string     `json:"working_dir,omitempty"`
+	Status      string     `json:"status"`
+	ExitCode    *int       `json:"exit_code,omitempty"`
+	Signal      string     `json:"signal,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	StdoutBytes int64      `json:"stdout_bytes"`
+	StderrBytes int64      `json:"stderr_bytes"`
+	Pty         bool       `json:"pty,omitempty"`
+}
+
+type ProcessOutput struct {
+	Stdout       string
+	Stderr       string
+	StdoutCursor int64
+	StderrCursor int64
+	// StdoutDropped and StderrDropped count bytes between the requested cursor
+	// and the oldest output still buffered.
+	StdoutDropped int64
+	StderrDropped int64
+}
+
+type process struct {
+	id      string
+	owner   any
+	cmd     *exec.Cmd
+	command string
+	args    []string
+	dir     string
+	started time.Time
+	stdout  *outputBuffer
+	stderr  *outputBuffer
+	timer   *time.Timer
+
+	pty       *os.File
+	ptyCopied chan struct{}
+	stripANSI bool
+
+	// Set before done is closed.
+	done     chan struct{}
+	exitCode int
+	signal   string
+	ended    time.Time
+}
+
+// ProcessManager owns commands started in the background by the run tool. It
+// caps how many run at once, buffers their output for incremental reads and
+// stops whatever is still running on shutdown.
+type ProcessManager struct {
+	maxRunning int
+
+	mu     sync.Mutex
+	nextID int
+	procs  map[string]*process
+	closed bool
+}
+
+func NewProcessManager(maxRunning int) *ProcessManager {
+	if maxRunning <= 0 {
+		maxRunning = DefaultMaxProcesses
+	}
+	return &ProcessManager{
+		maxRunning: maxRunning,
+		procs:      make(map[string]*process),
+	}
+}
+
+func (m *ProcessManager) Start(input StartProcessInput) (ProcessInfo, error) {
+	if input.Command == "" {
+		return ProcessInfo{}, fmt.Errorf("command is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ProcessInfo{}, fmt.Errorf("process manager is shut down")
+	}
+	if running := m.countRunning(); running >= m.maxRunning {
+		return ProcessInfo{}, fmt.Errorf("too many background processes running (limit %d); wait for or stop one first", m.maxRunning)
+	}
+
+	cmd := exec.Command(input.Command, input.Args...)
+	cmd.Dir = input.WorkingDir
+	cmd.Env = input.Env
+
+	p := &process{
+		owner:     input.Owner,
+		cmd:       cmd,
+		command:   input.Command,
+		args:      input.Args,
+		dir:       input.WorkingDir,
+		stdout:    &outputBuffer{},
+		stderr:    &outputBuffer{},
+		stripANSI: input.StripANSI,
+		done:      make(chan struct{}),
+	}
+	if err := input.Isolation.prepareCmd(cmd); err != nil {
+		return ProcessInfo{}, err
+	}
+
+	if input.Pty != nil {
+		master, err := startPty(cmd, input.Pty, input.Stdin)
+		if err != nil {
+			return ProcessInfo{}, fmt.Errorf("failed to start command: %w", err)
+		}
+		p.pty = master
+		p.ptyCopied = make(chan struct{})
+		go copyPty(p.stdout, master, p.ptyCopied)
+	} else {
+		if input.Stdin != "" {
+			cmd.Stdin = strings.NewReader(input.Stdin)
+		}
+		cmd.Stdout = p.stdout
+		cmd.Stderr = p.stderr
+		// Children that inherit the pipes must not keep Wait from returning.
+		cmd.WaitDelay = time.Second
+		if err := cmd.Start(); err != nil {
+			return ProcessInfo{}, fmt.Errorf("failed to start command: %w", err)
+		}
+	}
+	p.started = time.Now()
+
+	m.nextID++
+	p.id = fmt.Sprintf("p%d", m.nextID)
+	m.procs[p.id] = p
+	m.pruneFinished()
+
+	if input.TimeoutSeconds > 0 {
+		p.timer = time.AfterFunc(time.Duration(input.TimeoutSeconds)*time.Second, func() {
+			stopProcess(p)
+		})
+	}
+
+	go p.wait()
+
+	return p.info(), nil
+}
+
+func (p *process) wait() {
+	err := p.cmd.Wait()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if p.pty != nil {
+		closePty(p.pty, p.ptyCopied)
+	}
+
+	p.ended = time.Now()
+	p.exitCode = 0
+	if err != nil {
+		p.exitCode = -1
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			p.exitCode = exitErr.ExitCode()
+		}
+	}
+	if p.cmd.ProcessState != nil {
+		p.signal = exitSignal(p.cmd.ProcessState)
+	}
+	close(p.done)
+}
+
+func (p *process) running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *process) info() ProcessInfo {
+	info := ProcessInfo{
+		ID:          p.id,
+		PID:         p.cmd.Process.Pid,
+		Command:     p.command,
+		Args:        p.args,
+		WorkingDir:  p.dir,
+		Status:      ProcessRunning,
+		StartedAt:   p.started,
+		StdoutBytes: p.stdout.Size(),
+		StderrBytes: p.stderr.Size(),
+		Pty:         p.pty != nil,
+	}
+	if p.running() {
+		return info
+	}
+
+	ended := p.ended
+	info.EndedAt = &ended
+	if p.signal != "" {
+		info.Status = ProcessSignaled
+		info.Signal = p.signal
+		return info
+	}
+	exitCode := p.exitCode
+	info.Status = ProcessExited
+	info.ExitCode = &exitCode
+	return info
+}
+
+func (m *ProcessManager) lookup(id string, owner any) (*process, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p, ok := m.procs[id]
+	if !ok || p.owner != owner {
+		return nil, fmt.Errorf("process not found: %s", id)
+	}
+	return p, nil
+}
+
+func (m *ProcessManager) Info(id string, owner any) (ProcessInfo, error) {
+	p, err := m.lookup(id, owner)
+	if err != nil {
+		return ProcessInfo{}, err
+	}
+	return p.info(), nil
+}
+
+// List returns the owner's processes, oldest first.
+func (m *ProcessManager) List(owner any) []ProcessInfo {
+	m.mu.Lock()
+	var procs []*process
+	for _, p := range m.procs {
+		if p.owner == owner {
+			procs = append(procs, p)
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(procs, func(i, j int) bool { return procs[i].started.Before(procs[j].started) })
+
+	infos := make([]ProcessInfo, len(procs))
+	for i, p := range procs {
+		infos[i] = p.info()
+	}
+	return infos
+}
+
+// Output returns what each stream wrote since its cursor, up to maxBytes per
+// stream (0 for everything buffered), with the cursors to pass next time.
+func (m *ProcessManager) Output(id string, owner any, stdoutCursor, stderrCursor int64, maxBytes int) (ProcessOutput, error) {
+	p, err := m.lookup(id, owner)
+	if err != nil {
+		return ProcessOutput{}, err
+	}
+
+	var output ProcessOutput
+	output.Stdout, output.StdoutCursor, output.StdoutDropped = p.stdout.ReadFrom(stdoutCursor, maxBytes)
+	output.Stderr, output.StderrCursor, output.StderrDropped = p.stderr.ReadFrom(stderrCursor, maxBytes)
+	output.Stdout = cleanOutput(output.Stdout, p.pty != nil, p.stripANSI)
+	output.Stderr = cleanOutput(output.Stderr, p.pty != nil, p.stripANSI)
+	return output, nil
+}
+
+// Input writes data to the terminal of a process started with a pty, as if
+// it were typed.
+func (m *ProcessManager) Input(id string, owner any, data []byte) error {
+	p, err := m.lookup(id, owner)
+	if err != nil {
+		return err
+	}
+	if p.pty == nil {
+		return fmt.Errorf("process %s was not started with pty: true", id)
+	}
+	if !p.running() {
+		return fmt.Errorf("process %s has already exited", id)
+	}
+
+	if _, err := p.pty.Write(data); err != nil {
+		return fmt.Errorf("failed to write to process %s: %w", id, err)
+	}
+	return nil
+}
+
+func (m *ProcessManager) Signal(id string, owner any, signal string) error {
+	p, err := m.lookup(id, owner)
+	if err != nil {
+		return err
+	}
+	if !p.running() {
+		return fmt.Errorf("process %s has already exited", id)
+	}
+
+	sig, err := ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	if err := signalProcess(p.cmd.Process, sig); err != nil {
+		return fmt.Errorf("failed to signal process %s: %w", id, err)
+	}
+	return nil
+}
+
+// Wait blocks until the process exits, the timeout passes or ctx is done. The
+// returned bool reports whether the process had exited.
+func (m *ProcessManager) Wait(ctx context.Context, id string, owner any, timeout time.Duration) (ProcessInfo, bool, error) {
+	p, err := m.lookup(id, owner)
+	if err != nil {
+		return ProcessInfo{}, false, err
+	}
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case <-p.done:
+		return p.info(), true, nil
+	case <-timeoutCh:
+	case <-ctx.Done():
+	}
+	return p.info(), false, nil
+}
+
+// StopOwner stops every running process started by owner, e.g. when its client
+// session closes.
+func (m *ProcessManager) StopOwner(owner any) {
+	m.mu.Lock()
+	var procs []*process
+	for _, p := range m.procs {
+		if p.owner == owner {
+			procs = append(procs, p)
+			delete(m.procs, p.id)
+		}
+	}
+	m.mu.Unlock()
+
+	stopAll(procs)
+}
+
+// Shutdown stops all running processes and refuses new ones.
+func (m *ProcessManager) Shutdown() {
+	m.mu.Lock()
+	m.closed = true
+	procs := make([]*process, 0, len(m.procs))
+	for _, p := range m.procs {
+		procs = append(procs, p)
+	}
+	m.mu.Unlock()
+
+	stopAll(procs)
+}
+
+func stopAll(procs []*process) {
+	var wg sync.WaitGroup
+	for _, p := range procs {
+		if !p.running() {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopProcess(p)
+		}()
+	}
+	wg.Wait()
+}
+
+// stopProcess asks the process group to terminate and kills it if the
+// process is still running after the grace period.
+func stopProcess(p *process) {
+	if err := terminateProcess(p.cmd.Process); err != nil {
+		_ = killProcess(p.cmd.Process)
+	}
+
+	select {
+	case <-p.done:
+	case <-time.After(processStopGrace):
+		_ = killProcess(p.cmd.Process)
+		<-p.done
+	}
+}
+
+func (m *ProcessManager) countRunning() int {
+	count := 0
+	for _, p := range m.procs {
+		if p.running() {
+			count++
+		}
+	}
+	return count
+}
+
+func (m *ProcessManager) pruneFinished() {
+	var finished []*process
+	for _, p := range m.procs {
+		if !p.running() {
+			finished = append(finished, p)
+		}
+	}
+	if len(finished) <= maxFinishedProcesses {
+		return
+	}
+
+	sort.Slice(finished, func(i, j int) bool { return finished[i].ended.Before(finished[j].ended) })
+	for _, p := range finished[:len(finished)-maxFinishedProcesses] {
+		delete(m.procs, p.id)
+	}
+}
+
+// outputBuffer keeps the most recent processBufferLimit bytes of a stream,
+// addressed by absolute offset so readers can resume from a cursor. data may
+// hold up to twice the limit so that it is only compacted once in a while
+// rather than on every write; bytes before the last processBufferLimit are
+// never read.
+type outputBuffer struct {
+	mu   sync.Mutex
+	data []byte
+	base int64
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.data = append(b.data, p...)
+	if over := len(b.data) - processBufferLimit; len(b.data) > 2*processBufferLimit {
+		b.data = append([]byte(nil), b.data[over:]...)
+		b.base += int64(over)
+	}
+	return len(p), nil
+}
+
+// window returns the retained bytes and the offset of the first one.
+func (b *outputBuffer) window() ([]byte, int64) {
+	if over := len(b.data) - processBufferLimit; over > 0 {
+		return b.data[over:], b.base + int64(over)
+	}
+	return b.data, b.base
+}
+
+func (b *outputBuffer) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.base + int64(len(b.data))
+}
+
+func (b *outputBuffer) ReadFrom(cursor int64, maxBytes int) (string, int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data, base := b.window()
+	end := base + int64(len(data))
+	if cursor < 0 {
+		cursor = 0
+	}
+	if cursor > end {
+		cursor = end
+	}
+
+	var dropped int64
+	if cursor < base {
+		dropped = base - cursor
+		cursor = base
+	}
+
+	chunk := data[cursor-base:]
+	if maxBytes > 0 && len(chunk) > maxBytes {
+		chunk = chunk[:maxBytes]
+	}
+	return string(chunk), cursor + int64(len(chunk)), dropped
+}
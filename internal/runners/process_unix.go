@@ -0,0 +1,83 @@
+//go:build !windows
+
+package runners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+	"STOP": syscall.SIGSTOP,
+	"CONT": syscall.SIGCONT,
+}
+
+// ParseSignal accepts names with or without the SIG prefix, or a number.
+func ParseSignal(name string) (os.Signal, error) {
+	if name == "" {
+		return syscall.SIGTERM, nil
+	}
+	if number, err := strconv.Atoi(name); err == nil && number > 0 {
+		return syscall.Signal(number), nil
+	}
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported signal: %s", name)
+}
+
+// setProcessGroup starts cmd as the leader of a new process group so that
+// cancelling it (e.g. on timeout) kills everything it spawned.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	// Only commands made with CommandContext have (and may have) a Cancel.
+	if cmd.Cancel != nil {
+		cmd.Cancel = func() error {
+			return killProcess(cmd.Process)
+		}
+	}
+}
+
+// signalProcess signals the process group led by p.
+func signalProcess(p *os.Process, sig os.Signal) error {
+	number, ok := sig.(syscall.Signal)
+	if !ok {
+		return p.Signal(sig)
+	}
+	return syscall.Kill(-p.Pid, number)
+}
+
+func terminateProcess(p *os.Process) error {
+	return signalProcess(p, syscall.SIGTERM)
+}
+
+func killProcess(p *os.Process) error {
+	return signalProcess(p, syscall.SIGKILL)
+}
+
+func exitSignal(state *os.ProcessState) string {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+	for name, sig := range signalNames {
+		if sig == status.Signal() {
+			return "SIG" + name
+		}
+	}
+	return strconv.Itoa(int(status.Signal()))
+}
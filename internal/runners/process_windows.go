@@ -0,0 +1,40 @@
+//go:build windows
+
+package runners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ParseSignal maps the signals Windows can deliver; anything that would
+// terminate the process becomes a kill.
+func ParseSignal(name string) (os.Signal, error) {
+	switch strings.TrimPrefix(strings.ToUpper(name), "SIG") {
+	case "", "TERM", "KILL", "9", "15":
+		return os.Kill, nil
+	case "INT", "2":
+		return os.Interrupt, nil
+	}
+	return nil, fmt.Errorf("unsupported signal on windows: %s", name)
+}
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func signalProcess(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+func terminateProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+func killProcess(p *os.Process) error {
+	return p.Kill()
+}
+
+func exitSignal(state *os.ProcessState) string {
+	return ""
+}
@@ -0,0 +1,102 @@
+package runners
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPtyRows = 24
+	DefaultPtyCols = 80
+
+	// ptyDrainTimeout is how long output is still read after the command
+	// exits, in case something it started keeps the terminal open.
+	ptyDrainTimeout = time.Second
+)
+
+// PtyOptions runs a command on a pseudo-terminal instead of pipes. The
+// terminal merges stdout and stderr, so all output is reported as stdout.
+type PtyOptions struct {
+	Rows int
+	Cols int
+}
+
+func (o *PtyOptions) size() (int, int) {
+	rows, cols := DefaultPtyRows, DefaultPtyCols
+	if o.Rows > 0 {
+		rows = o.Rows
+	}
+	if o.Cols > 0 {
+		cols = o.Cols
+	}
+	return rows, cols
+}
+
+// ansiPattern matches CSI sequences (colours, cursor movement), OSC sequences
+// (titles, hyperlinks) and the remaining two-byte escapes.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// StripANSI removes terminal escape sequences from s.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\x1b") {
+		return s
+	}
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// cleanOutput undoes what a terminal does to output: it turns the \r\n the
+// line discipline writes back into \n and optionally strips escapes.
+func cleanOutput(s string, pty, stripANSI bool) string {
+	if pty {
+		s = strings.ReplaceAll(s, "\r\n", "\n")
+	}
+	if stripANSI {
+		s = StripANSI(s)
+	}
+	return s
+}
+
+// startPty starts cmd on a new pseudo-terminal and returns its master side.
+// stdin, if any, is typed into the terminal.
+func startPty(cmd *exec.Cmd, opts *PtyOptions, stdin string) (*os.File, error) {
+	rows, cols := opts.size()
+	master, slave, err := openPty(rows, cols)
+	if err != nil {
+		return nil, err
+	}
+	attachPty(cmd, slave)
+
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+
+	if stdin != "" {
+		go master.Write([]byte(stdin))
+	}
+	return master, nil
+}
+
+// copyPty copies the terminal's output to w until the terminal closes, and
+// closes done when it returns.
+func copyPty(w io.Writer, master *os.File, done chan<- struct{}) {
+	defer close(done)
+	// Reads fail with EIO once every process has closed the terminal.
+	_, _ = io.Copy(w, master)
+}
+
+// closePty waits for the output copy to finish and closes the master.
+func closePty(master *os.File, copied <-chan struct{}) {
+	select {
+	case <-copied:
+	case <-time.After(ptyDrainTimeout):
+	}
+	master.Close()
+	<-copied
+}
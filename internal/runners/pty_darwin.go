@@ -0,0 +1,50 @@
+package runners
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openPty allocates a pseudo-terminal pair through /dev/ptmx, which macOS
+// hands out after grantpt/unlockpt, and sets its window size.
+func openPty(rows, cols int) (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+
+	if err := ptyIoctl(master, syscall.TIOCPTYGRANT, nil); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to grant pty: %w", err)
+	}
+	if err := ptyIoctl(master, syscall.TIOCPTYUNLK, nil); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	var name [128]byte
+	if err := ptyIoctl(master, syscall.TIOCPTYGNAME, unsafe.Pointer(&name[0])); err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to get pty name: %w", err)
+	}
+	slavePath := string(name[:])
+	if end := bytes.IndexByte(name[:], 0); end >= 0 {
+		slavePath = string(name[:end])
+	}
+
+	slave, err := os.OpenFile(slavePath, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+
+	if err := setPtySize(master, rows, cols); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, nil, err
+	}
+
+	return master, slave, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+
+package runners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+func openPty(rows, cols int) (*os.File, *os.File, error) {
+	return nil, nil, fmt.Errorf("pty is only supported on Linux and macOS")
+}
+
+func attachPty(cmd *exec.Cmd, slave *os.File) {}
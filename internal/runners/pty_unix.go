@@ -0,0 +1,41 @@
+//go:build linux || darwin
+
+package runners
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// attachPty makes the pty the command's stdio and controlling terminal. The
+// command starts a new session, which also makes it a process group leader.
+func attachPty(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setpgid = false
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+func setPtySize(master *os.File, rows, cols int) error {
+	size := struct{ rows, cols, x, y uint16 }{uint16(rows), uint16(cols), 0, 0}
+	if err := ptyIoctl(master, syscall.TIOCSWINSZ, unsafe.Pointer(&size)); err != nil {
+		return fmt.Errorf("failed to set pty size: %w", err)
+	}
+	return nil
+}
+
+func ptyIoctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -1,10 +1,16 @@
 package runners
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -15,7 +21,10 @@ func NewRipgrepRunner() *RipgrepRunner {
 }
 
 type RipgrepSearchInput struct {
-	Pattern         string
+	Pattern string
+	// Dir is the directory rg runs in. Path, when relative, and the paths
+	// in the result are relative to it.
+	Dir             string
 	Path            string
 	Glob            string
 	Type            string
@@ -29,10 +38,50 @@ type RipgrepSearchInput struct {
 	Multiline       bool
 }
 
-func (r *RipgrepRunner) Search(ctx context.Context, input RipgrepSearchInput) (string, error) {
-	args := []string{}
+type RipgrepResult struct {
+	Files []RipgrepFile
+	// Text renders the result the way rg prints it in the requested output
+	// mode.
+	Text string
+	// Truncated reports that HeadLimit cut the result short.
+	Truncated bool
+}
+
+// RipgrepFile is a file with matches. files_with_matches mode fills in only
+// Path, count mode adds MatchedLines and content mode everything.
+type RipgrepFile struct {
+	Path         string         `json:"path"`
+	MatchedLines int            `json:"matched_lines,omitempty"`
+	MatchCount   int            `json:"match_count,omitempty"`
+	Matches      []RipgrepMatch `json:"matches,omitempty"`
+}
+
+// RipgrepMatch is a matching line (several lines for multiline matches).
+// Column and submatch offsets are in bytes; Column is 1-based and
+// AbsoluteOffset is the offset of the line's start in the file.
+type RipgrepMatch struct {
+	LineNumber     int               `json:"line_number"`
+	Column         int               `json:"column"`
+	AbsoluteOffset int64             `json:"absolute_offset"`
+	Text           string            `json:"text"`
+	Submatches     []RipgrepSubmatch `json:"submatches"`
+	ContextBefore  []RipgrepLine     `json:"context_before,omitempty"`
+	ContextAfter   []RipgrepLine     `json:"context_after,omitempty"`
+}
 
-	args = append(args, input.Pattern)
+type RipgrepSubmatch struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type RipgrepLine struct {
+	LineNumber int    `json:"line_number"`
+	Text       string `json:"text"`
+}
+
+func (r *RipgrepRunner) Search(ctx context.Context, input RipgrepSearchInput) (RipgrepResult, error) {
+	args := []string{}
 
 	if input.CaseInsensitive {
 		args = append(args, "-i")
@@ -42,29 +91,26 @@ func (r *RipgrepRunner) Search(ctx context.Context, input RipgrepSearchInput) (s
 		args = append(args, "-U", "--multiline-dotall")
 	}
 
+	before, after := 0, 0
 	switch input.OutputMode {
 	case "files_with_matches":
-		args = append(args, "-l")
+		args = append(args, "-l", "--null")
 	case "count":
-		args = append(args, "-c")
+		args = append(args, "-c", "--null", "--with-filename")
 	case "content":
-
-		if input.LineNumbers {
-			args = append(args, "-n")
-		}
-
+		args = append(args, "--json")
+		before, after = input.ContextBefore, input.ContextAfter
 		if input.Context > 0 {
-			args = append(args, fmt.Sprintf("-C%d", input.Context))
-		} else {
-			if input.ContextBefore > 0 {
-				args = append(args, fmt.Sprintf("-B%d", input.ContextBefore))
-			}
-			if input.ContextAfter > 0 {
-				args = append(args, fmt.Sprintf("-A%d", input.ContextAfter))
-			}
+			before, after = input.Context, input.Context
+		}
+		if before > 0 {
+			args = append(args, fmt.Sprintf("-B%d", before))
+		}
+		if after > 0 {
+			args = append(args, fmt.Sprintf("-A%d", after))
 		}
 	default:
-		return "", fmt.Errorf("invalid output_mode: %s", input.OutputMode)
+		return RipgrepResult{}, fmt.Errorf("invalid output_mode: %s", input.OutputMode)
 	}
 
 	if input.Type != "" {
@@ -75,11 +121,14 @@ func (r *RipgrepRunner) Search(ctx context.Context, input RipgrepSearchInput) (s
 		args = append(args, "--glob", input.Glob)
 	}
 
+	args = append(args, "-e", input.Pattern)
+
 	if input.Path != "" {
-		args = append(args, input.Path)
+		args = append(args, "--", input.Path)
 	}
 
 	cmd := exec.CommandContext(ctx, "rg", args...)
+	cmd.Dir = input.Dir
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -88,23 +137,301 @@ func (r *RipgrepRunner) Search(ctx context.Context, input RipgrepSearchInput) (s
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
-			return "No matches found", nil
+			return RipgrepResult{Text: "No matches found"}, nil
 		}
 		if stderr.Len() > 0 {
-			return "", fmt.Errorf("ripgrep error: %s", stderr.String())
+			return RipgrepResult{}, fmt.Errorf("ripgrep error: %s", stderr.String())
+		}
+		return RipgrepResult{}, fmt.Errorf("ripgrep command failed: %w", err)
+	}
+
+	var files []RipgrepFile
+	if input.OutputMode == "content" {
+		files, err = parseRipgrepJSON(stdout.Bytes(), before, after)
+	} else {
+		files, err = parseRipgrepList(stdout.Bytes(), input.OutputMode == "count")
+	}
+	if err != nil {
+		return RipgrepResult{}, err
+	}
+
+	// Like rg, name files only when searching more than a single file.
+	withFilename := true
+	searchPath := input.Path
+	if searchPath != "" && !filepath.IsAbs(searchPath) {
+		searchPath = filepath.Join(input.Dir, searchPath)
+	}
+	if info, err := os.Stat(searchPath); err == nil && !info.IsDir() {
+		withFilename = false
+	}
+
+	renderer := ripgrepRenderer{
+		mode:         input.OutputMode,
+		withFilename: withFilename,
+		lineNumbers:  input.LineNumbers,
+		separators:   before > 0 || after > 0,
+		limit:        input.HeadLimit,
+	}
+	return renderer.render(files), nil
+}
+
+type ripgrepEvent struct {
+	Type string `json:"type"`
+	Data struct {
+		Path           ripgrepData `json:"path"`
+		Lines          ripgrepData `json:"lines"`
+		LineNumber     int         `json:"line_number"`
+		AbsoluteOffset int64       `json:"absolute_offset"`
+		Submatches     []struct {
+			Match ripgrepData `json:"match"`
+			Start int         `json:"start"`
+			End   int         `json:"end"`
+		} `json:"submatches"`
+		Stats struct {
+			MatchedLines int `json:"matched_lines"`
+			Matches      int `json:"matches"`
+		} `json:"stats"`
+	} `json:"data"`
+}
+
+// parseRipgrepList reads the NUL-terminated paths rg prints with -l --null,
+// each followed by :COUNT when counts is set (-c).
+func parseRipgrepList(data []byte, counts bool) ([]RipgrepFile, error) {
+	var files []RipgrepFile
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, 0)
+		if end < 0 {
+			return nil, fmt.Errorf("failed to parse ripgrep output: missing path terminator")
+		}
+		file := RipgrepFile{Path: string(data[:end])}
+		data = data[end+1:]
+
+		if counts {
+			line, rest, _ := bytes.Cut(data, []byte("\n"))
+			count, err := strconv.Atoi(strings.TrimPrefix(string(line), ":"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ripgrep count for %s: %w", file.Path, err)
+			}
+			file.MatchedLines = count
+			data = rest
+		} else if len(data) > 0 && data[0] == '\n' {
+			data = data[1:]
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// ripgrepData is rg's encoding of paths and text: UTF-8 in text, anything
+// else base64-encoded in bytes.
+type ripgrepData struct {
+	Text  *string `json:"text"`
+	Bytes string  `json:"bytes"`
+}
+
+func (d ripgrepData) String() string {
+	if d.Text != nil {
+		return *d.Text
+	}
+	decoded, err := base64.StdEncoding.DecodeString(d.Bytes)
+	if err != nil {
+		return d.Bytes
+	}
+	return string(decoded)
+}
+
+// ripgrepLine is a match or context line of a file in output order.
+type ripgrepLine struct {
+	match   bool
+	number  int
+	text    string
+	matchAt int
+}
+
+func parseRipgrepJSON(data []byte, before, after int) ([]RipgrepFile, error) {
+	var files []RipgrepFile
+	var lines []ripgrepLine
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var event ripgrepEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed to parse ripgrep output: %w", err)
+		}
+
+		switch event.Type {
+		case "begin":
+			files = append(files, RipgrepFile{Path: event.Data.Path.String()})
+			lines = nil
+		case "match", "context":
+			if len(files) == 0 {
+				continue
+			}
+			file := &files[len(files)-1]
+			line := ripgrepLine{
+				match:  event.Type == "match",
+				number: event.Data.LineNumber,
+				text:   strings.TrimSuffix(event.Data.Lines.String(), "\n"),
+			}
+			if line.match {
+				match := RipgrepMatch{
+					LineNumber:     event.Data.LineNumber,
+					AbsoluteOffset: event.Data.AbsoluteOffset,
+					Text:           line.text,
+					Submatches:     []RipgrepSubmatch{},
+				}
+				for _, sub := range event.Data.Submatches {
+					match.Submatches = append(match.Submatches, RipgrepSubmatch{
+						Text:  sub.Match.String(),
+						Start: sub.Start,
+						End:   sub.End,
+					})
+				}
+				if len(match.Submatches) > 0 {
+					match.Column = match.Submatches[0].Start + 1
+				}
+				line.matchAt = len(file.Matches)
+				file.Matches = append(file.Matches, match)
+			}
+			lines = append(lines, line)
+		case "end":
+			if len(files) == 0 {
+				continue
+			}
+			file := &files[len(files)-1]
+			file.MatchedLines = event.Data.Stats.MatchedLines
+			file.MatchCount = event.Data.Stats.Matches
+			attachContext(file, lines, before, after)
+			lines = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ripgrep output: %w", err)
+	}
+	return files, nil
+}
+
+// attachContext gives every match the context lines within its own window. A
+// line between two close matches is context for both.
+func attachContext(file *RipgrepFile, lines []ripgrepLine, before, after int) {
+	for i, line := range lines {
+		if !line.match {
+			continue
+		}
+		match := &file.Matches[line.matchAt]
+		first := line.number
+		last := line.number + strings.Count(line.text, "\n")
+
+		for j := i - 1; j >= 0 && !lines[j].match && lines[j].number >= first-before; j-- {
+			match.ContextBefore = append([]RipgrepLine{{LineNumber: lines[j].number, Text: lines[j].text}}, match.ContextBefore...)
+		}
+		for j := i + 1; j < len(lines) && !lines[j].match && lines[j].number <= last+after; j++ {
+			match.ContextAfter = append(match.ContextAfter, RipgrepLine{LineNumber: lines[j].number, Text: lines[j].text})
+		}
+	}
+}
+
+type ripgrepRenderer struct {
+	mode         string
+	withFilename bool
+	lineNumbers  bool
+	separators   bool
+	limit        int
+
+	out       []string
+	truncated bool
+}
+
+func (r *ripgrepRenderer) full() bool {
+	if r.limit > 0 && len(r.out) >= r.limit {
+		r.truncated = true
+		return true
+	}
+	return false
+}
+
+// render builds the text output and trims the files to what fits in the
+// limit, so both stay in step.
+func (r *ripgrepRenderer) render(files []RipgrepFile) RipgrepResult {
+	var kept []RipgrepFile
+	for _, file := range files {
+		if r.full() {
+			break
 		}
-		return "", fmt.Errorf("ripgrep command failed: %w", err)
+
+		switch r.mode {
+		case "files_with_matches":
+			r.out = append(r.out, file.Path)
+			file.Matches = nil
+		case "count":
+			if r.withFilename {
+				r.out = append(r.out, file.Path+":"+strconv.Itoa(file.MatchedLines))
+			} else {
+				r.out = append(r.out, strconv.Itoa(file.MatchedLines))
+			}
+			file.Matches = nil
+		default:
+			file.Matches = r.renderMatches(file)
+		}
+		kept = append(kept, file)
 	}
 
-	result := stdout.String()
+	// A match with context or spanning lines can overshoot the limit.
+	if r.limit > 0 && len(r.out) > r.limit {
+		r.out = r.out[:r.limit]
+		r.truncated = true
+	}
 
-	if input.HeadLimit > 0 && result != "" {
-		lines := strings.Split(result, "\n")
-		if len(lines) > input.HeadLimit {
-			lines = lines[:input.HeadLimit]
+	return RipgrepResult{
+		Files:     kept,
+		Text:      strings.Join(r.out, "\n"),
+		Truncated: r.truncated,
+	}
+}
+
+func (r *ripgrepRenderer) renderMatches(file RipgrepFile) []RipgrepMatch {
+	var kept []RipgrepMatch
+	next := 0
+	for i, match := range file.Matches {
+		if r.full() {
+			break
+		}
+		if i == 0 && r.separators && len(r.out) > 0 {
+			r.out = append(r.out, "--")
+		}
+		for _, line := range match.ContextBefore {
+			if line.LineNumber >= next {
+				r.line(file.Path, line.LineNumber, "-", line.Text, &next)
+			}
+		}
+		r.line(file.Path, match.LineNumber, ":", match.Text, &next)
+		for _, line := range match.ContextAfter {
+			if line.LineNumber >= next {
+				r.line(file.Path, line.LineNumber, "-", line.Text, &next)
+			}
 		}
-		result = strings.Join(lines, "\n")
+		kept = append(kept, match)
 	}
+	return kept
+}
 
-	return strings.TrimSpace(result), nil
+// line renders one match or context entry, which spans several lines for
+// multiline matches. next tracks the line after the last one rendered, to
+// skip context shared by neighbouring matches and to place separators.
+func (r *ripgrepRenderer) line(path string, number int, sep, text string, next *int) {
+	if r.separators && *next > 0 && number > *next {
+		r.out = append(r.out, "--")
+	}
+	for i, text := range strings.Split(text, "\n") {
+		prefix := ""
+		if r.withFilename {
+			prefix = path + sep
+		}
+		if r.lineNumbers {
+			prefix += strconv.Itoa(number+i) + sep
+		}
+		r.out = append(r.out, prefix+text)
+	}
+	*next = number + strings.Count(text, "\n") + 1
 }
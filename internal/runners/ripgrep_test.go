@@ -0,0 +1,251 @@
+package runners
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ripgrepJSONOutput is what `rg --json -C1 foo` prints for two files: one
+// with two matches that share a context line, and one whose path and line
+// are not UTF-8 and so arrive base64-encoded.
+const ripgrepJSONOutput = `{"type":"begin","data":{"path":{"text":"a.go"}}}
+{"type":"context","data":{"path":{"text":"a.go"},"lines":{"text":"package a\n"},"line_number":1,"absolute_offset":0,"submatches":[]}}
+{"type":"match","data":{"path":{"text":"a.go"},"lines":{"text":"foo bar foo\n"},"line_number":2,"absolute_offset":10,"submatches":[{"match":{"text":"foo"},"start":0,"end":3},{"match":{"text":"foo"},"start":8,"end":11}]}}
+{"type":"context","data":{"path":{"text":"a.go"},"lines":{"text":"x\n"},"line_number":3,"absolute_offset":22,"submatches":[]}}
+{"type":"match","data":{"path":{"text":"a.go"},"lines":{"text":"  foo\n"},"line_number":4,"absolute_offset":24,"submatches":[{"match":{"text":"foo"},"start":2,"end":5}]}}
+{"type":"context","data":{"path":{"text":"a.go"},"lines":{"text":"y\n"},"line_number":5,"absolute_offset":30,"submatches":[]}}
+{"type":"end","data":{"path":{"text":"a.go"},"binary_offset":null,"stats":{"matched_lines":2,"matches":3}}}
+{"type":"begin","data":{"path":{"bytes":"Yv9jLnR4dA=="}}}
+{"type":"match","data":{"path":{"bytes":"Yv9jLnR4dA=="},"lines":{"bytes":"/2Zvbwo="},"line_number":1,"absolute_offset":0,"submatches":[{"match":{"text":"foo"},"start":1,"end":4}]}}
+{"type":"end","data":{"path":{"bytes":"Yv9jLnR4dA=="},"binary_offset":null,"stats":{"matched_lines":1,"matches":1}}}
+{"data":{"elapsed_total":{"human":"0.001s","nanos":1,"secs":0},"stats":{"matches":4}},"type":"summary"}
+`
+
+func TestParseRipgrepJSON(t *testing.T) {
+	files, err := parseRipgrepJSON([]byte(ripgrepJSONOutput), 1, 1)
+	if err != nil {
+		t.Fatalf("parseRipgrepJSON: %v", err)
+	}
+
+	want := []RipgrepFile{
+		{
+			Path:         "a.go",
+			MatchedLines: 2,
+			MatchCount:   3,
+			Matches: []RipgrepMatch{
+				{
+					LineNumber:     2,
+					Column:         1,
+					AbsoluteOffset: 10,
+					Text:           "foo bar foo",
+					Submatches:     []RipgrepSubmatch{{Text: "foo", Start: 0, End: 3}, {Text: "foo", Start: 8, End: 11}},
+					ContextBefore:  []RipgrepLine{{LineNumber: 1, Text: "package a"}},
+					ContextAfter:   []RipgrepLine{{LineNumber: 3, Text: "x"}},
+				},
+				{
+					LineNumber:     4,
+					Column:         3,
+					AbsoluteOffset: 24,
+					Text:           "  foo",
+					Submatches:     []RipgrepSubmatch{{Text: "foo", Start: 2, End: 5}},
+					ContextBefore:  []RipgrepLine{{LineNumber: 3, Text: "x"}},
+					ContextAfter:   []RipgrepLine{{LineNumber: 5, Text: "y"}},
+				},
+			},
+		},
+		{
+			Path:         "b\xffc.txt",
+			MatchedLines: 1,
+			MatchCount:   1,
+			Matches: []RipgrepMatch{
+				{
+					LineNumber: 1,
+					Column:     2,
+					Text:       "\xfffoo",
+					Submatches: []RipgrepSubmatch{{Text: "foo", Start: 1, End: 4}},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("parseRipgrepJSON =\n%+v\nwant\n%+v", files, want)
+	}
+}
+
+func TestParseRipgrepJSONContextWindows(t *testing.T) {
+	// Matches on lines 2-3 (multiline) and 9 with -B1 -A2: line 5 is after
+	// the first match's window and line 7 is before the second's.
+	output := `{"type":"begin","data":{"path":{"text":"m.txt"}}}
+{"type":"context","data":{"lines":{"text":"one\n"},"line_number":1}}
+{"type":"match","data":{"lines":{"text":"two\nthree\n"},"line_number":2,"submatches":[{"match":{"text":"o\nth"},"start":2,"end":6}]}}
+{"type":"context","data":{"lines":{"text":"four\n"},"line_number":4}}
+{"type":"context","data":{"lines":{"text":"five\n"},"line_number":5}}
+{"type":"context","data":{"lines":{"text":"seven\n"},"line_number":7}}
+{"type":"context","data":{"lines":{"text":"eight\n"},"line_number":8}}
+{"type":"match","data":{"lines":{"text":"nine\n"},"line_number":9,"submatches":[]}}
+{"type":"end","data":{"stats":{"matched_lines":3,"matches":2}}}
+`
+	files, err := parseRipgrepJSON([]byte(output), 1, 2)
+	if err != nil {
+		t.Fatalf("parseRipgrepJSON: %v", err)
+	}
+	if len(files) != 1 || len(files[0].Matches) != 2 {
+		t.Fatalf("parseRipgrepJSON = %+v, want one file with two matches", files)
+	}
+
+	first, second := files[0].Matches[0], files[0].Matches[1]
+	if first.Text != "two\nthree" || first.Column != 3 {
+		t.Errorf("first match = %q at column %d, want %q at column 3", first.Text, first.Column, "two\nthree")
+	}
+	if want := []RipgrepLine{{1, "one"}}; !reflect.DeepEqual(first.ContextBefore, want) {
+		t.Errorf("first ContextBefore = %v, want %v", first.ContextBefore, want)
+	}
+	if want := []RipgrepLine{{4, "four"}, {5, "five"}}; !reflect.DeepEqual(first.ContextAfter, want) {
+		t.Errorf("first ContextAfter = %v, want %v", first.ContextAfter, want)
+	}
+	if want := []RipgrepLine{{8, "eight"}}; !reflect.DeepEqual(second.ContextBefore, want) {
+		t.Errorf("second ContextBefore = %v, want %v", second.ContextBefore, want)
+	}
+	if second.Column != 0 || second.Submatches == nil || len(second.Submatches) != 0 {
+		t.Errorf("second match = %+v, want column 0 and empty submatches", second)
+	}
+}
+
+func TestParseRipgrepJSONErrors(t *testing.T) {
+	if _, err := parseRipgrepJSON([]byte("not json\n"), 0, 0); err == nil || !strings.Contains(err.Error(), "failed to parse ripgrep output") {
+		t.Errorf("parseRipgrepJSON error = %v, want a parse error", err)
+	}
+	// Lines before any begin event are ignored.
+	files, err := parseRipgrepJSON([]byte(`{"type":"match","data":{"lines":{"text":"x"},"line_number":1}}`+"\n"), 0, 0)
+	if err != nil || files != nil {
+		t.Errorf("parseRipgrepJSON = %+v, %v, want no files", files, err)
+	}
+}
+
+func TestParseRipgrepList(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		counts bool
+		want   []RipgrepFile
+	}{
+		{"empty", "", false, nil},
+		{"files", "a.go\x00dir/b:c.go\x00", false, []RipgrepFile{{Path: "a.go"}, {Path: "dir/b:c.go"}}},
+		{"files with newlines", "a.go\x00\nb\nc.go\x00\n", false, []RipgrepFile{{Path: "a.go"}, {Path: "b\nc.go"}}},
+		{"counts", "a.go\x003\nb:2:c.go\x0012\n", true, []RipgrepFile{{Path: "a.go", MatchedLines: 3}, {Path: "b:2:c.go", MatchedLines: 12}}},
+		{"counts with colon", "a.go\x00:7\n", true, []RipgrepFile{{Path: "a.go", MatchedLines: 7}}},
+		{"count without newline", "a.go\x005", true, []RipgrepFile{{Path: "a.go", MatchedLines: 5}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRipgrepList([]byte(tt.data), tt.counts)
+			if err != nil {
+				t.Fatalf("parseRipgrepList: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRipgrepList = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := parseRipgrepList([]byte("a.go"), false); err == nil || !strings.Contains(err.Error(), "missing path terminator") {
+		t.Errorf("parseRipgrepList without NUL error = %v, want missing terminator", err)
+	}
+	if _, err := parseRipgrepList([]byte("a.go\x00x\n"), true); err == nil || !strings.Contains(err.Error(), "count for a.go") {
+		t.Errorf("parseRipgrepList with a bad count error = %v, want a count error", err)
+	}
+}
+
+func TestRipgrepRenderer(t *testing.T) {
+	files, err := parseRipgrepJSON([]byte(ripgrepJSONOutput), 1, 1)
+	if err != nil {
+		t.Fatalf("parseRipgrepJSON: %v", err)
+	}
+	far := RipgrepFile{Path: "far.go", Matches: []RipgrepMatch{
+		{LineNumber: 1, Text: "foo", ContextAfter: []RipgrepLine{{2, "a"}}},
+		{LineNumber: 10, Text: "foo", ContextBefore: []RipgrepLine{{9, "b"}}},
+	}}
+
+	tests := []struct {
+		name      string
+		renderer  ripgrepRenderer
+		files     []RipgrepFile
+		want      []string
+		kept      int
+		truncated bool
+	}{
+		{
+			name:     "files",
+			renderer: ripgrepRenderer{mode: "files_with_matches", withFilename: true},
+			files:    []RipgrepFile{{Path: "a.go"}, {Path: "b.go"}},
+			want:     []string{"a.go", "b.go"},
+			kept:     2,
+		},
+		{
+			name:      "files over the limit",
+			renderer:  ripgrepRenderer{mode: "files_with_matches", withFilename: true, limit: 1},
+			files:     []RipgrepFile{{Path: "a.go"}, {Path: "b.go"}},
+			want:      []string{"a.go"},
+			kept:      1,
+			truncated: true,
+		},
+		{
+			name:     "count",
+			renderer: ripgrepRenderer{mode: "count", withFilename: true},
+			files:    []RipgrepFile{{Path: "a:b.go", MatchedLines: 2}},
+			want:     []string{"a:b.go:2"},
+			kept:     1,
+		},
+		{
+			name:     "count of a single file",
+			renderer: ripgrepRenderer{mode: "count"},
+			files:    []RipgrepFile{{Path: "a.go", MatchedLines: 2}},
+			want:     []string{"2"},
+			kept:     1,
+		},
+		{
+			name:     "content with shared context",
+			renderer: ripgrepRenderer{mode: "content", withFilename: true, lineNumbers: true, separators: true},
+			files:    files[:1],
+			want:     []string{"a.go-1-package a", "a.go:2:foo bar foo", "a.go-3-x", "a.go:4:  foo", "a.go-5-y"},
+			kept:     1,
+		},
+		{
+			name:     "separators between groups and files",
+			renderer: ripgrepRenderer{mode: "content", lineNumbers: true, separators: true},
+			files:    []RipgrepFile{far, far},
+			want:     []string{"1:foo", "2-a", "--", "9-b", "10:foo", "--", "1:foo", "2-a", "--", "9-b", "10:foo"},
+			kept:     2,
+		},
+		{
+			name:     "no separators without context",
+			renderer: ripgrepRenderer{mode: "content", withFilename: true},
+			files:    []RipgrepFile{{Path: "a.go", Matches: []RipgrepMatch{{LineNumber: 1, Text: "x"}, {LineNumber: 7, Text: "y\nz"}}}},
+			want:     []string{"a.go:x", "a.go:y", "a.go:z"},
+			kept:     1,
+		},
+		{
+			name:      "content over the limit",
+			renderer:  ripgrepRenderer{mode: "content", lineNumbers: true, separators: true, limit: 4},
+			files:     files,
+			want:      []string{"1-package a", "2:foo bar foo", "3-x", "4:  foo"},
+			kept:      1,
+			truncated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.renderer.render(tt.files)
+			if want := strings.Join(tt.want, "\n"); result.Text != want {
+				t.Errorf("Text =\n%s\nwant\n%s", result.Text, want)
+			}
+			if len(result.Files) != tt.kept {
+				t.Errorf("kept %d files, want %d", len(result.Files), tt.kept)
+			}
+			if result.Truncated != tt.truncated {
+				t.Errorf("Truncated = %v, want %v", result.Truncated, tt.truncated)
+			}
+		})
+	}
+}
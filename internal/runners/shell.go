@@ -0,0 +1,213 @@
+package runners
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellPrelude runs the caller's script with eval so that cd and export take
+// effect in the same shell, then records the final directory and environment
+// on exit (including an explicit exit in the script) for the next call.
+const shellPrelude = `__ctm_state=$1
+__ctm_script=$2
+set --
+__ctm_save() {
+	__ctm_status=$?
+	{ pwd -P; printf '\000'; env -0; } >"$__ctm_state" 2>/dev/null
+	exit $__ctm_status
+}
+trap __ctm_save EXIT
+eval "$__ctm_script"`
+
+// Variables the shell maintains itself; carrying them over would be stale.
+var shellManagedEnv = map[string]bool{
+	"PWD":   true,
+	"SHLVL": true,
+	"_":     true,
+}
+
+// ShellSession is the terminal-like state shared by shell-mode runs within a
+// client session: the working directory and environment left by the last
+// command. Runs on the same session are serialized.
+type ShellSession struct {
+	mu  sync.Mutex
+	dir string
+	env map[string]string
+}
+
+func NewShellSession(dir string, environ []string) *ShellSession {
+	return &ShellSession{dir: dir, env: parseEnviron(environ)}
+}
+
+func (s *ShellSession) Dir() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+// Snapshot returns the session's directory and environment with env applied,
+// for shell commands that start from the session without updating it.
+func (s *ShellSession) Snapshot(env map[string]string) (string, []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	merged := make(map[string]string, len(s.env)+len(env))
+	for key, value := range s.env {
+		merged[key] = value
+	}
+	for key, value := range env {
+		merged[key] = value
+	}
+	return s.dir, formatEnviron(merged)
+}
+
+type RunShellInput struct {
+	Shell          string
+	Script         string
+	WorkingDir     string
+	TimeoutSeconds int
+	Stdin          string
+	Env            map[string]string
+	MaxOutputBytes int
+	Spool          *OutputSpool
+	Isolation      *Isolation
+	OnOutput       OutputFunc
+	Pty            *PtyOptions
+	StripANSI      bool
+}
+
+// DefaultShell picks bash when it is on PATH, falling back to sh.
+func DefaultShell() string {
+	if path, err := exec.LookPath("bash"); err == nil {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		return "bash"
+	}
+	return "/bin/sh"
+}
+
+func RunShell(ctx context.Context, session *ShellSession, input RunShellInput) (RunCommandResult, error) {
+	if input.Script == "" {
+		return RunCommandResult{}, fmt.Errorf("command is required")
+	}
+
+	shell := input.Shell
+	if shell == "" {
+		shell = DefaultShell()
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	dir := session.dir
+	if input.WorkingDir != "" {
+		dir = input.WorkingDir
+	}
+
+	env := make(map[string]string, len(session.env)+len(input.Env))
+	for key, value := range session.env {
+		env[key] = value
+	}
+	for key, value := range input.Env {
+		env[key] = value
+	}
+
+	stateFile, err := os.CreateTemp("", "code-tools-shell-*")
+	if err != nil {
+		return RunCommandResult{}, fmt.Errorf("failed to create shell state file: %w", err)
+	}
+	statePath := stateFile.Name()
+	stateFile.Close()
+	defer os.Remove(statePath)
+
+	var cancel context.CancelFunc
+	if input.TimeoutSeconds > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(input.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, shell, "-c", shellPrelude, "code-tools-shell", statePath, input.Script)
+	cmd.Dir = dir
+	cmd.Env = formatEnviron(env)
+	if err := input.Isolation.prepareCmd(cmd, statePath); err != nil {
+		return RunCommandResult{}, err
+	}
+
+	capture := newOutputCapture(input.MaxOutputBytes, input.Spool, input.OnOutput)
+	result, err := runCmd(cmd, input.Stdin, capture, input.Pty, input.StripANSI)
+	if err != nil {
+		return RunCommandResult{}, err
+	}
+
+	if state, err := os.ReadFile(statePath); err == nil && len(state) > 0 {
+		if newDir, newEnv, ok := parseShellState(state); ok {
+			// Per-call env values are not exported into the session unless
+			// the script changed them itself.
+			for key, value := range input.Env {
+				if newEnv[key] != value {
+					continue
+				}
+				if previous, existed := session.env[key]; existed {
+					newEnv[key] = previous
+				} else {
+					delete(newEnv, key)
+				}
+			}
+			session.dir = newDir
+			session.env = newEnv
+		}
+	}
+
+	result.WorkingDir = session.dir
+	return result, nil
+}
+
+func parseShellState(state []byte) (string, map[string]string, bool) {
+	idx := bytes.IndexByte(state, 0)
+	if idx < 0 {
+		return "", nil, false
+	}
+	dir := strings.TrimRight(string(state[:idx]), "\r\n")
+	if dir == "" {
+		return "", nil, false
+	}
+
+	var entries []string
+	for _, entry := range bytes.Split(state[idx+1:], []byte{0}) {
+		if len(entry) > 0 {
+			entries = append(entries, string(entry))
+		}
+	}
+	if len(entries) == 0 {
+		return "", nil, false
+	}
+	return dir, parseEnviron(entries), true
+}
+
+func parseEnviron(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, entry := range environ {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || shellManagedEnv[key] {
+			continue
+		}
+		env[key] = value
+	}
+	return env
+}
+
+func formatEnviron(env map[string]string) []string {
+	environ := make([]string, 0, len(env))
+	for key, value := range env {
+		environ = append(environ, key+"="+value)
+	}
+	return environ
+}
@@ -0,0 +1,308 @@
+package runners
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	shellAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+	// shellKeywords may precede a command without changing what runs.
+	shellKeywords = map[string]bool{
+		"!": true, "if": true, "then": true, "else": true, "elif": true,
+		"do": true, "while": true, "until": true, "time": true,
+	}
+	// shellClosers end a compound command and run nothing themselves.
+	shellClosers = map[string]bool{"fi": true, "done": true, "esac": true}
+
+	// shellWrappers run the rest of their arguments as a command. Without
+	// options the wrapped command is simply the next word.
+	shellWrappers = map[string]bool{
+		"command": true, "builtin": true, "exec": true, "nohup": true, "env": true,
+	}
+
+	// opaqueCommands run commands that cannot be read off their argv, or
+	// open compound syntax this splitter does not follow.
+	opaqueCommands = map[string]bool{
+		"eval": true, "source": true, ".": true, "sudo": true, "doas": true,
+		"su": true, "xargs": true, "timeout": true, "nice": true, "ionice": true,
+		"stdbuf": true, "setsid": true, "watch": true, "parallel": true,
+		"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true,
+		"fish": true, "busybox": true, "for": true, "case": true,
+		"select": true, "function": true, "coproc": true, "trap": true,
+	}
+)
+
+// ShellScript returns the script of a `sh -c SCRIPT ...` style invocation
+// of a known shell.
+func ShellScript(command string, args []string) (string, bool) {
+	switch filepath.Base(command) {
+	case "sh", "bash", "zsh", "dash", "ksh":
+	default:
+		return "", false
+	}
+	for i, arg := range args {
+		if arg == "-c" && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+type shellWord struct {
+	text string
+	// expands is set when the word contains an unquoted or double-quoted $.
+	expands bool
+}
+
+// splitShellScript splits a script into the simple commands it runs, as argv
+// with quotes and redirections removed. It stops and reports false at
+// anything that hides which commands run: command substitution, subshells and
+// groups, here-documents, functions, loops and case, expansions in command
+// position and commands that run other commands (eval, xargs, sudo, ...).
+// The commands found up to that point are still returned.
+func splitShellScript(script string) ([][]string, bool) {
+	lexer := shellLexer{input: []rune(script)}
+	ok := lexer.run()
+
+	var commands [][]string
+	for _, words := range lexer.commands {
+		argv, decidable := simpleCommand(words)
+		if !decidable {
+			return commands, false
+		}
+		if len(argv) > 0 {
+			commands = append(commands, argv)
+		}
+	}
+	return commands, ok
+}
+
+func simpleCommand(words []shellWord) ([]string, bool) {
+	for len(words) > 0 {
+		word := words[0]
+		switch {
+		case shellAssignment.MatchString(word.text):
+			words = words[1:]
+		case shellKeywords[word.text]:
+			words = words[1:]
+		case shellClosers[word.text]:
+			return nil, len(words) == 1
+		default:
+			if word.expands || word.text == "{" || word.text == "}" {
+				return nil, false
+			}
+			argv := make([]string, len(words))
+			for i, w := range words {
+				argv[i] = w.text
+			}
+			inner, ok := unwrapCommand(argv)
+			if !ok {
+				return nil, false
+			}
+			// The wrapped command must not come from an expansion either.
+			if len(inner) > 0 && words[len(words)-len(inner)].expands {
+				return nil, false
+			}
+			return inner, true
+		}
+	}
+	return nil, true
+}
+
+// unwrapCommand looks through wrappers (env, nohup, command, ...) in front of
+// argv and returns the command that actually runs, or nil when the wrappers
+// run nothing. It reports false when that cannot be told from argv: a
+// wrapper given options, an opaque command or find with -exec and the like.
+func unwrapCommand(argv []string) ([]string, bool) {
+	for len(argv) > 0 {
+		name := filepath.Base(argv[0])
+		switch {
+		case shellWrappers[name]:
+			if len(argv) > 1 && strings.HasPrefix(argv[1], "-") {
+				return nil, false
+			}
+			argv = argv[1:]
+			if name == "env" {
+				for len(argv) > 0 && shellAssignment.MatchString(argv[0]) {
+					argv = argv[1:]
+				}
+			}
+		case opaqueCommands[name]:
+			return nil, false
+		default:
+			// find runs commands through its -exec family of actions.
+			if name == "find" {
+				for _, arg := range argv[1:] {
+					if strings.HasPrefix(arg, "-exec") || strings.HasPrefix(arg, "-ok") {
+						return nil, false
+					}
+				}
+			}
+			return argv, true
+		}
+	}
+	return nil, true
+}
+
+type shellLexer struct {
+	input []rune
+	pos   int
+
+	commands [][]shellWord
+	words    []shellWord
+	word     strings.Builder
+	inWord   bool
+	expands  bool
+	// redirect drops the next word, the target of a redirection.
+	redirect bool
+}
+
+func (l *shellLexer) peek(offset int) rune {
+	if l.pos+offset < len(l.input) {
+		return l.input[l.pos+offset]
+	}
+	return 0
+}
+
+func (l *shellLexer) endWord() {
+	if !l.inWord {
+		return
+	}
+	if l.redirect {
+		l.redirect = false
+	} else {
+		l.words = append(l.words, shellWord{text: l.word.String(), expands: l.expands})
+	}
+	l.word.Reset()
+	l.inWord, l.expands = false, false
+}
+
+func (l *shellLexer) endCommand() {
+	l.endWord()
+	if len(l.words) > 0 {
+		l.commands = append(l.commands, l.words)
+	}
+	l.words = nil
+}
+
+func (l *shellLexer) run() bool {
+	for ; l.pos < len(l.input); l.pos++ {
+		r := l.input[l.pos]
+		switch {
+		case r == ' ' || r == '\t':
+			l.endWord()
+		case r == '\n' || r == ';' || r == '|':
+			l.endCommand()
+		case r == '&':
+			if l.peek(1) == '>' {
+				l.endWord()
+				l.pos++
+				if !l.redirection() {
+					return false
+				}
+				continue
+			}
+			l.endCommand()
+		case r == '#' && !l.inWord:
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+			l.endCommand()
+		case r == '\'':
+			l.inWord = true
+			end := l.pos + 1
+			for end < len(l.input) && l.input[end] != '\'' {
+				end++
+			}
+			if end == len(l.input) {
+				return false
+			}
+			l.word.WriteString(string(l.input[l.pos+1 : end]))
+			l.pos = end
+		case r == '"':
+			if !l.doubleQuoted() {
+				return false
+			}
+		case r == '\\':
+			l.pos++
+			if l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.inWord = true
+				l.word.WriteRune(l.input[l.pos])
+			}
+		case r == '`' || r == '(' || r == ')':
+			return false
+		case r == '$':
+			if l.peek(1) == '(' {
+				return false
+			}
+			l.inWord, l.expands = true, true
+			l.word.WriteRune(r)
+		case r == '<' || r == '>':
+			// A file descriptor number before the operator belongs to it.
+			if l.inWord && !l.expands && strings.Trim(l.word.String(), "0123456789") == "" {
+				l.word.Reset()
+				l.inWord = false
+			}
+			l.endWord()
+			if !l.redirection() {
+				return false
+			}
+		default:
+			l.inWord = true
+			l.word.WriteRune(r)
+		}
+	}
+	l.endCommand()
+	return true
+}
+
+// redirection consumes a redirection operator at pos; its target is the next
+// word. Here-documents and process substitution are not followed.
+func (l *shellLexer) redirection() bool {
+	r := l.input[l.pos]
+	if r == '<' && l.peek(1) == '<' {
+		return false
+	}
+	if l.peek(1) == '(' {
+		return false
+	}
+	for next := l.peek(1); next == '>' || next == '&' || next == '|' || (r == '<' && next == '>'); next = l.peek(1) {
+		l.pos++
+	}
+	l.redirect = true
+	return true
+}
+
+func (l *shellLexer) doubleQuoted() bool {
+	l.inWord = true
+	for l.pos++; l.pos < len(l.input); l.pos++ {
+		r := l.input[l.pos]
+		switch r {
+		case '"':
+			return true
+		case '`':
+			return false
+		case '$':
+			if l.peek(1) == '(' {
+				return false
+			}
+			l.expands = true
+		case '\\':
+			if next := l.peek(1); next == '"' || next == '\\' || next == '$' || next == '`' {
+				l.pos++
+				r = next
+			} else if next == '\n' {
+				l.pos++
+				continue
+			}
+		}
+		l.word.WriteRune(r)
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package runners
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShellScript(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+		script  string
+		ok      bool
+	}{
+		{"bash", []string{"-c", "ls"}, "ls", true},
+		{"/bin/sh", []string{"-e", "-c", "ls; pwd"}, "ls; pwd", true},
+		{"zsh", []string{"-lc"}, "", false},
+		{"bash", []string{"script.sh", "-c", "ls"}, "", false},
+		{"python", []string{"-c", "print(1)"}, "", false},
+	}
+	for _, tt := range tests {
+		script, ok := ShellScript(tt.command, tt.args)
+		if script != tt.script || ok != tt.ok {
+			t.Errorf("ShellScript(%q, %q) = %q, %v, want %q, %v", tt.command, tt.args, script, ok, tt.script, tt.ok)
+		}
+	}
+}
+
+func TestSplitShellScript(t *testing.T) {
+	tests := []struct {
+		script string
+		want   [][]string
+	}{
+		{"ls -la", [][]string{{"ls", "-la"}}},
+		{"ls; pwd\necho hi", [][]string{{"ls"}, {"pwd"}, {"echo", "hi"}}},
+		{"make && git push || echo failed", [][]string{{"make"}, {"git", "push"}, {"echo", "failed"}}},
+		{"cat a | grep b &", [][]string{{"cat", "a"}, {"grep", "b"}}},
+		{`echo 'a b' "c d" e\ f`, [][]string{{"echo", "a b", "c d", "e f"}}},
+		{`echo "say \"hi\""`, [][]string{{"echo", `say "hi"`}}},
+		{"echo '$(not run)'", [][]string{{"echo", "$(not run)"}}},
+		{"ls # rm -rf /", [][]string{{"ls"}}},
+
+		// Redirection targets are not arguments.
+		{"go test > out.txt 2>&1", [][]string{{"go", "test"}}},
+		{"make &> build.log; ls", [][]string{{"make"}, {"ls"}}},
+		{"sort < in.txt >> out.txt", [][]string{{"sort"}}},
+		{"cmd 2> err.log 1>&2", [][]string{{"cmd"}}},
+		{"echo a2>b", [][]string{{"echo", "a2"}}},
+
+		// Prefixes that do not change which command runs.
+		{"FOO=1 BAR=2 git push", [][]string{{"git", "push"}}},
+		{"env FOO=1 git push", [][]string{{"git", "push"}}},
+		{"nohup git push &", [][]string{{"git", "push"}}},
+		{"command git push", [][]string{{"git", "push"}}},
+		{"if true; then git push; fi", [][]string{{"true"}, {"git", "push"}}},
+		{"while false; do ls; done", [][]string{{"false"}, {"ls"}}},
+		{"! git diff --quiet", [][]string{{"git", "diff", "--quiet"}}},
+		{"echo $HOME", [][]string{{"echo", "$HOME"}}},
+	}
+	for _, tt := range tests {
+		got, ok := splitShellScript(tt.script)
+		if !ok {
+			t.Errorf("splitShellScript(%q) is undecidable, want %q", tt.script, tt.want)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitShellScript(%q) = %q, want %q", tt.script, got, tt.want)
+		}
+	}
+}
+
+func TestSplitShellScriptUndecidable(t *testing.T) {
+	scripts := []string{
+		"echo $(git push)",
+		"echo `git push`",
+		"(cd x && git push)",
+		"{ git push; }",
+		"cat <<EOF\nx\nEOF",
+		"diff <(ls a) <(ls b)",
+		"eval git push",
+		"sudo git push",
+		"xargs git push < list",
+		"timeout 5 git push",
+		"bash -c 'git push'",
+		"for f in *; do rm $f; done",
+		"$CMD push",
+		"env $CMD push",
+		"env -i git push",
+		"find . -exec rm {} ;",
+		"find . -name x -okdir rm {} +",
+		"echo 'unterminated",
+		`echo "unterminated`,
+	}
+	for _, script := range scripts {
+		if got, ok := splitShellScript(script); ok {
+			t.Errorf("splitShellScript(%q) = %q, want it undecidable", script, got)
+		}
+	}
+}
+
+func TestUnwrapCommand(t *testing.T) {
+	tests := []struct {
+		argv []string
+		want []string
+		ok   bool
+	}{
+		{[]string{"git", "push"}, []string{"git", "push"}, true},
+		{[]string{"env", "A=1", "B=2", "git", "push"}, []string{"git", "push"}, true},
+		{[]string{"/usr/bin/env", "git", "push"}, []string{"git", "push"}, true},
+		{[]string{"nohup", "command", "git", "push"}, []string{"git", "push"}, true},
+		{[]string{"env"}, nil, true},
+		{[]string{"env", "-i", "git"}, nil, false},
+		{[]string{"sudo", "ls"}, nil, false},
+		{[]string{"nohup", "timeout", "5", "ls"}, nil, false},
+		{[]string{"find", ".", "-execdir", "rm", "{}", ";"}, nil, false},
+		{[]string{"find", ".", "-name", "x"}, []string{"find", ".", "-name", "x"}, true},
+	}
+	for _, tt := range tests {
+		got, ok := unwrapCommand(tt.argv)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("unwrapCommand(%q) = %q, %v, want %q, %v", tt.argv, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package runners
+
+import (
+	"bytes"
+)
+
+const (
+	ContentText   = "text"
+	ContentBinary = "binary"
+	ContentImage  = "image"
+
+	sniffLength = 8000
+)
+
+// SniffContent classifies data as an image, text or binary by looking at its
+// first few kilobytes: known image signatures win, text with a byte order mark
+// or a UTF-16 layout is text, NUL bytes or a high share of control characters
+// mean binary.
+func SniffContent(data []byte) string {
+	if len(data) > sniffLength {
+		data = data[:sniffLength]
+	}
+
+	if SniffImageType(data) != "" {
+		return ContentImage
+	}
+
+	if bytes.HasPrefix(data, bomUTF8) || bytes.HasPrefix(data, bomUTF16LE) || bytes.HasPrefix(data, bomUTF16BE) {
+		return ContentText
+	}
+	if _, ok := sniffUTF16(data[:len(data)&^1]); ok {
+		return ContentText
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return ContentBinary
+	}
+
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && !isTextControl(b) || b == 0x7F {
+			control++
+		}
+	}
+	if len(data) > 0 && control*10 > len(data) {
+		return ContentBinary
+	}
+
+	return ContentText
+}
+
+func isTextControl(b byte) bool {
+	switch b {
+	case '\t', '\n', '\r', '\f', '\v', '\b', 0x1B:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+package runners
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1"
+
+	LineEndingLF    = "lf"
+	LineEndingCRLF  = "crlf"
+	LineEndingCR    = "cr"
+	LineEndingMixed = "mixed"
+	LineEndingNone  = "none"
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+type TextFormat struct {
+	Encoding   string
+	BOM        bool
+	LineEnding string
+}
+
+func DefaultTextFormat() TextFormat {
+	return TextFormat{Encoding: EncodingUTF8, LineEnding: LineEndingLF}
+}
+
+// DecodeText detects the encoding of data, strips any byte order mark and
+// returns the content as UTF-8 with its original line endings intact.
+func DecodeText(data []byte) (string, TextFormat) {
+	format := TextFormat{Encoding: EncodingUTF8}
+
+	var text string
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		format.BOM = true
+		text = string(data[len(bomUTF8):])
+	case bytes.HasPrefix(data, bomUTF16LE):
+		format.Encoding = EncodingUTF16LE
+		format.BOM = true
+		text = decodeUTF16(data[len(bomUTF16LE):], binary.LittleEndian)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		format.Encoding = EncodingUTF16BE
+		format.BOM = true
+		text = decodeUTF16(data[len(bomUTF16BE):], binary.BigEndian)
+	default:
+		if encoding, ok := sniffUTF16(data); ok {
+			format.Encoding = encoding
+			if encoding == EncodingUTF16LE {
+				text = decodeUTF16(data, binary.LittleEndian)
+			} else {
+				text = decodeUTF16(data, binary.BigEndian)
+			}
+		} else if utf8.Valid(data) {
+			text = string(data)
+		} else {
+			format.Encoding = EncodingLatin1
+			runes := make([]rune, len(data))
+			for i, b := range data {
+				runes[i] = rune(b)
+			}
+			text = string(runes)
+		}
+	}
+
+	format.LineEnding = DetectLineEnding(text)
+	return text, format
+}
+
+func EncodeText(text string, format TextFormat) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch strings.ToLower(format.Encoding) {
+	case "", EncodingUTF8, "utf8":
+		if format.BOM {
+			buf.Write(bomUTF8)
+		}
+		buf.WriteString(text)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		order := binary.ByteOrder(binary.LittleEndian)
+		bom := bomUTF16LE
+		if strings.ToLower(format.Encoding) == EncodingUTF16BE {
+			order = binary.BigEndian
+			bom = bomUTF16BE
+		}
+		if format.BOM {
+			buf.Write(bom)
+		}
+		for _, unit := range utf16.Encode([]rune(text)) {
+			var b [2]byte
+			order.PutUint16(b[:], unit)
+			buf.Write(b[:])
+		}
+	case EncodingLatin1, "latin1", "iso-8859-1":
+		for _, r := range text {
+			if r > 0xFF {
+				return nil, fmt.Errorf("character %q cannot be encoded as latin-1", r)
+			}
+			buf.WriteByte(byte(r))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", format.Encoding)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func DetectLineEnding(text string) string {
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	cr := strings.Count(text, "\r") - crlf
+
+	kinds := 0
+	ending := LineEndingNone
+	if crlf > 0 {
+		kinds++
+		ending = LineEndingCRLF
+	}
+	if lf > 0 {
+		kinds++
+		ending = LineEndingLF
+	}
+	if cr > 0 {
+		kinds++
+		ending = LineEndingCR
+	}
+	if kinds > 1 {
+		return LineEndingMixed
+	}
+	return ending
+}
+
+// NormalizeLineEndings rewrites every line break in text to the given style.
+// Mixed or unknown styles leave text untouched.
+func NormalizeLineEndings(text, ending string) string {
+	var sep string
+	switch ending {
+	case LineEndingLF:
+		sep = "\n"
+	case LineEndingCRLF:
+		sep = "\r\n"
+	case LineEndingCR:
+		sep = "\r"
+	default:
+		return text
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	if sep == "\n" {
+		return text
+	}
+	return strings.ReplaceAll(text, "\n", sep)
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(units))
+}
+
+func sniffUTF16(data []byte) (string, bool) {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return "", false
+	}
+
+	evenZeros, oddZeros := 0, 0
+	evenText, oddText := 0, 0
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		} else if isTextByte(data[i]) {
+			evenText++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		} else if isTextByte(data[i+1]) {
+			oddText++
+		}
+	}
+
+	pairs := len(data) / 2
+	switch {
+	case oddZeros*10 >= pairs*7 && evenZeros*10 < pairs && evenText*10 >= pairs*7:
+		return EncodingUTF16LE, true
+	case evenZeros*10 >= pairs*7 && oddZeros*10 < pairs && oddText*10 >= pairs*7:
+		return EncodingUTF16BE, true
+	}
+	return "", false
+}
+
+func isTextByte(b byte) bool {
+	return b >= 0x20 && b != 0x7F || b == '\t' || b == '\n' || b == '\r'
+}
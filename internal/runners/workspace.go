@@ -0,0 +1,158 @@
+package runners
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrOutsideWorkspace = errors.New("path is outside the workspace roots")
+
+type Workspace struct {
+	roots []string
+}
+
+func NewWorkspace(roots []string) (*Workspace, error) {
+	if len(roots) == 0 {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current directory: %w", err)
+		}
+		roots = []string{cwd}
+	}
+
+	resolved, err := ResolveRoots(roots)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Workspace{roots: resolved}, nil
+}
+
+func (w *Workspace) Roots() []string {
+	return append([]string(nil), w.roots...)
+}
+
+func (w *Workspace) IsRoot(path string, clientRoots []string) bool {
+	for _, root := range append(w.Roots(), clientRoots...) {
+		if path == root {
+			return true
+		}
+	}
+	return false
+}
+
+// RootOf returns the deepest workspace root that contains path, or "" when
+// none does.
+func (w *Workspace) RootOf(path string) string {
+	best := ""
+	for _, root := range w.roots {
+		if within(path, root) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+func ResolveRoots(roots []string) ([]string, error) {
+	resolved := make([]string, 0, len(roots))
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		path, err := resolvePath(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve workspace root %s: %w", root, err)
+		}
+		resolved = append(resolved, path)
+	}
+	return resolved, nil
+}
+
+// Resolve returns the absolute form of path with every symlink in its parent
+// directories evaluated. The path is rejected unless both that form and the
+// fully resolved target (when the final element is itself a symlink) fall
+// inside one of the workspace roots and, when clientRoots is non-empty, inside
+// one of those as well.
+func (w *Workspace) Resolve(path string, clientRoots []string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("path is required")
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	parent, err := resolvePath(filepath.Dir(absPath))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	lexical := filepath.Join(parent, filepath.Base(absPath))
+	if filepath.Dir(absPath) == absPath {
+		lexical = parent
+	}
+
+	target, err := resolvePath(lexical)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	for _, candidate := range []string{lexical, target} {
+		if !withinAny(candidate, w.roots) {
+			return "", fmt.Errorf("%w: %s (allowed roots: %s)", ErrOutsideWorkspace, path, strings.Join(w.roots, ", "))
+		}
+		if len(clientRoots) > 0 && !withinAny(candidate, clientRoots) {
+			return "", fmt.Errorf("%w: %s (client roots: %s)", ErrOutsideWorkspace, path, strings.Join(clientRoots, ", "))
+		}
+	}
+
+	return lexical, nil
+}
+
+// resolvePath evaluates symlinks in the longest existing prefix of path and
+// appends the remaining, not yet created, elements unchanged.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing := absPath
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return absPath, nil
+		}
+		rest = append([]string{filepath.Base(existing)}, rest...)
+		existing = parent
+	}
+}
+
+func withinAny(path string, roots []string) bool {
+	for _, root := range roots {
+		if within(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type HTTPServerConfig struct {
+	Version         string
+	Config          *config.Config
+	Addr            string
+	BasePath        string
+	ShutdownTimeout time.Duration
+}
+
+func NewHTTPHandler(server *mcp.Server, basePath string) http.Handler {
+	basePath = normalizeBasePath(basePath)
+
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server {
+		return server
+	}, nil)
+
+	mux := http.NewServeMux()
+	mux.Handle(basePath, handler)
+	if basePath != "/" {
+		mux.Handle(basePath+"/", handler)
+	}
+
+	return mux
+}
+
+func normalizeBasePath(basePath string) string {
+	if basePath == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(basePath, "/") {
+		basePath = "/" + basePath
+	}
+	if len(basePath) > 1 {
+		basePath = strings.TrimRight(basePath, "/")
+	}
+	return basePath
+}
+
+func RunHTTPServer(cfg HTTPServerConfig) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	defer shutdownServer()
+
+	server, err := NewMCPServer(MCPServerConfig{
+		Version: cfg.Version,
+		Config:  cfg.Config,
+	})
+	if err != nil {
+		logger.Error("Failed to create MCP server", err)
+		return fmt.Errorf("failed to create MCP server: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		logger.Error("Failed to listen", err, "addr", cfg.Addr)
+		return fmt.Errorf("failed to listen on %s: %w", cfg.Addr, err)
+	}
+
+	return ServeHTTP(ctx, listener, server, cfg)
+}
+
+func ServeHTTP(ctx context.Context, listener net.Listener, server *mcp.Server, cfg HTTPServerConfig) error {
+	basePath := normalizeBasePath(cfg.BasePath)
+
+	httpServer := &http.Server{
+		Handler:           NewHTTPHandler(server, basePath),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.Serve(listener)
+	}()
+
+	logger.Info("Code Tools MCP Server started and running",
+		"version", cfg.Version,
+		"transport", "http",
+		"addr", listener.Addr().String(),
+		"base_path", basePath,
+	)
+
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Server stopped with error", err)
+			return err
+		}
+		logger.Info("Server stopped gracefully")
+		return nil
+	case <-ctx.Done():
+	}
+
+	shutdownTimeout := cfg.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = 10 * time.Second
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Server shutdown did not complete cleanly", err)
+		httpServer.Close()
+		return fmt.Errorf("failed to shut down HTTP server: %w", err)
+	}
+
+	logger.Info("Server stopped gracefully")
+	return nil
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newTestServer(t *testing.T) *mcp.Server {
+	t.Helper()
+
+	cfg := &config.Config{
+		Logging: config.LoggingConfig{Level: "ERROR", OutputFile: filepath.Join(t.TempDir(), "server.log")},
+	}
+	server, err := NewMCPServer(MCPServerConfig{Version: "test", Config: cfg})
+	if err != nil {
+		t.Fatalf("NewMCPServer: %v", err)
+	}
+	t.Cleanup(shutdownServer)
+	return server
+}
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":       "/",
+		"/":      "/",
+		"mcp":    "/mcp",
+		"/mcp":   "/mcp",
+		"/mcp/":  "/mcp",
+		"api/v1": "/api/v1",
+	}
+	for input, want := range tests {
+		if got := normalizeBasePath(input); got != want {
+			t.Errorf("normalizeBasePath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestHTTPHandlerInitialize(t *testing.T) {
+	for _, basePath := range []string{"", "mcp", "/mcp/"} {
+		t.Run(basePath, func(t *testing.T) {
+			ts := httptest.NewServer(NewHTTPHandler(newTestServer(t), basePath))
+			defer ts.Close()
+
+			endpoint := ts.URL + normalizeBasePath(basePath)
+			client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "test"}, nil)
+			session, err := client.Connect(context.Background(), &mcp.StreamableClientTransport{Endpoint: endpoint, MaxRetries: -1}, nil)
+			if err != nil {
+				t.Fatalf("Connect(%s): %v", endpoint, err)
+			}
+			defer session.Close()
+
+			if got := session.InitializeResult().ServerInfo.Name; got != "CodeToolsMcp" {
+				t.Errorf("server name = %q, want CodeToolsMcp", got)
+			}
+
+			tools, err := session.ListTools(context.Background(), nil)
+			if err != nil {
+				t.Fatalf("ListTools: %v", err)
+			}
+			if len(tools.Tools) == 0 {
+				t.Error("ListTools returned no tools")
+			}
+		})
+	}
+}
+
+func TestHTTPHandlerUnknownPath(t *testing.T) {
+	ts := httptest.NewServer(NewHTTPHandler(newTestServer(t), "/mcp"))
+	defer ts.Close()
+
+	resp, err := http.Post(ts.URL+"/other", "application/json", nil)
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestServeHTTPShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	addr := listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeHTTP(ctx, listener, newTestServer(t), HTTPServerConfig{
+			Version:         "test",
+			BasePath:        "/mcp",
+			ShutdownTimeout: 5 * time.Second,
+		})
+	}()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "test-client", Version: "test"}, nil)
+	session, err := client.Connect(context.Background(), &mcp.StreamableClientTransport{Endpoint: "http://" + addr + "/mcp", MaxRetries: -1}, nil)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	if err := session.Ping(context.Background(), nil); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	session.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ServeHTTP returned %v, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ServeHTTP did not return after the context was cancelled")
+	}
+
+	if conn, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		conn.Close()
+		t.Error("server still accepts connections after shutdown")
+	}
+}
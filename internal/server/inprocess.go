@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// InProcessClient drives the tool server over in-memory transports, so tools
+// run exactly as they would for a real MCP client. Call uses a single
+// session; NewSession opens more when callers need separate session state.
+type InProcessClient struct {
+	server  *mcp.Server
+	client  *mcp.Client
+	session *InProcessSession
+}
+
+// InProcessSession is one client session of an InProcessClient. Each session
+// has its own shell state, read-before-edit tracking and background processes.
+type InProcessSession struct {
+	session *mcp.ClientSession
+}
+
+func NewInProcessClient(ctx context.Context, cfg MCPServerConfig) (*InProcessClient, error) {
+	server, err := NewMCPServer(cfg)
+	if err != nil {
+		shutdownServer()
+		return nil, fmt.Errorf("failed to create MCP server: %w", err)
+	}
+
+	c := &InProcessClient{
+		server: server,
+		client: mcp.NewClient(&mcp.Implementation{Name: "CodeToolsMcp-cli", Version: cfg.Version}, nil),
+	}
+	session, err := c.NewSession(ctx)
+	if err != nil {
+		shutdownServer()
+		return nil, err
+	}
+	c.session = session
+
+	return c, nil
+}
+
+func (c *InProcessClient) NewSession(ctx context.Context) (*InProcessSession, error) {
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	if _, err := c.server.Connect(ctx, serverTransport, nil); err != nil {
+		return nil, fmt.Errorf("failed to connect in-process server: %w", err)
+	}
+
+	session, err := c.client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect in-process client: %w", err)
+	}
+
+	return &InProcessSession{session: session}, nil
+}
+
+func (c *InProcessClient) Call(ctx context.Context, tool string, args json.RawMessage) (*mcp.CallToolResult, error) {
+	return c.session.Call(ctx, tool, args)
+}
+
+func (c *InProcessClient) Close() error {
+	err := c.session.Close()
+	shutdownServer()
+	return err
+}
+
+func (s *InProcessSession) Call(ctx context.Context, tool string, args json.RawMessage) (*mcp.CallToolResult, error) {
+	if len(args) == 0 {
+		args = json.RawMessage(`{}`)
+	}
+	return s.session.CallTool(ctx, &mcp.CallToolParams{Name: tool, Arguments: args})
+}
+
+func (s *InProcessSession) Close() error {
+	return s.session.Close()
+}
@@ -7,12 +7,21 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/tools"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// State owned by the run tool that outlives individual calls; it is released
+// with the server.
+var (
+	processManager *runners.ProcessManager
+	outputSpool    *runners.OutputSpool
+)
+
 type MCPServerConfig struct {
 	Version string
 	Config  *config.Config
@@ -21,27 +30,77 @@ type MCPServerConfig struct {
 func NewMCPServer(cfg MCPServerConfig) (*mcp.Server, error) {
 	logCfg := logger.ConfigFromLoggingConfig(cfg.Config.Logging)
 	if err := logger.Initialize(logCfg); err != nil {
-		fmt.Printf("Warning: Failed to initialize logger: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: Failed to initialize logger: %v\n", err)
 	} else {
-		logger.Info("Logger initialized successfully", map[string]interface{}{
-			"level":       logger.LogLevelString(logCfg.Level),
-			"output_file": logCfg.OutputFile,
-			"console":     logCfg.Console,
-		})
+		logger.Info("Logger initialized successfully",
+			"log_level", logger.LogLevelString(logCfg.Level),
+			"log_format", logCfg.Format,
+			"output_file", logCfg.OutputFile,
+			"console", logCfg.Console,
+		)
 	}
 
-	impl := &mcp.Implementation{Name: "CodeToolsMcp", Version: cfg.Version}
-	server := mcp.NewServer(impl, nil)
+	if err := audit.Initialize(cfg.Config.Audit); err != nil {
+		return nil, err
+	}
+	if cfg.Config.Audit.OutputFile != "" {
+		logger.Info("Audit log enabled", "output_file", cfg.Config.Audit.OutputFile)
+	}
+
+	workspace, err := runners.NewWorkspace(cfg.Config.Workspace.Roots)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize workspace: %w", err)
+	}
+	guard := tools.NewWorkspaceGuard(workspace)
 
-	logger.Info("Code Tools MCP Server starting", map[string]interface{}{
-		"version": cfg.Version,
+	impl := &mcp.Implementation{Name: "CodeToolsMcp", Version: cfg.Version}
+	server := mcp.NewServer(impl, &mcp.ServerOptions{
+		RootsListChangedHandler: guard.RootsChanged,
+		InitializedHandler:      logSessionInitialized,
 	})
 
-	tools.RegisterTools(server, cfg.Config)
+	logger.Info("Code Tools MCP Server starting",
+		"version", cfg.Version,
+		"workspace_roots", workspace.Roots(),
+	)
+
+	processManager = runners.NewProcessManager(cfg.Config.Run.MaxProcesses)
+	outputSpool = runners.NewOutputSpool()
+	if err := tools.RegisterTools(server, cfg.Config, guard, processManager, outputSpool); err != nil {
+		return nil, fmt.Errorf("failed to register tools: %w", err)
+	}
+	logger.ForwardToClients(server)
 
 	return server, nil
 }
 
+func logSessionInitialized(ctx context.Context, req *mcp.InitializedRequest) {
+	ctx = logger.WithSession(ctx, req.Session)
+
+	var args []any
+	if params := req.Session.InitializeParams(); params != nil && params.ClientInfo != nil {
+		args = append(args, "client", params.ClientInfo.Name, "client_version", params.ClientInfo.Version)
+	}
+	logger.LogEvent(ctx, "Session initialized", nil, args...)
+}
+
+func shutdownServer() {
+	if processManager != nil {
+		processManager.Shutdown()
+	}
+	if outputSpool != nil {
+		if err := outputSpool.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing spooled run output: %v\n", err)
+		}
+	}
+	if err := audit.Shutdown(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error closing audit log: %v\n", err)
+	}
+	if err := logger.Shutdown(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error shutting down logger: %v\n", err)
+	}
+}
+
 type StdioServerConfig struct {
 	Version string
 	Config  *config.Config
@@ -51,11 +110,7 @@ func RunStdioServer(cfg StdioServerConfig) error {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	defer func() {
-		if err := logger.Shutdown(); err != nil {
-			fmt.Printf("Error shutting down logger: %v\n", err)
-		}
-	}()
+	defer shutdownServer()
 
 	server, err := NewMCPServer(MCPServerConfig{
 		Version: cfg.Version,
@@ -67,10 +122,10 @@ func RunStdioServer(cfg StdioServerConfig) error {
 		return fmt.Errorf("failed to create MCP server: %w", err)
 	}
 
-	logger.Info("Code Tools MCP Server started and running", map[string]interface{}{
-		"version": cfg.Version,
-	})
-	fmt.Printf("Code Tools MCP Server running ...\n")
+	logger.Info("Code Tools MCP Server started and running",
+		"version", cfg.Version,
+		"transport", "stdio",
+	)
 
 	err = server.Run(ctx, &mcp.StdioTransport{})
 	if err != nil {
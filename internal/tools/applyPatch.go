@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ApplyPatchToolName        = "apply_patch"
+	ApplyPatchToolDescription = `Applies a unified diff to one or more files.
+
+Usage:
+- Accepts plain unified diffs and git-style diffs, including new files, deletions and renames
+- Only git's rename from/rename to headers rename a file; a plain diff between two different names patches whichever of them exists, like patch(1)
+- Existing files that the patch modifies, renames or deletes must have been read with the read tool first
+- Paths in the patch are resolved relative to base_dir (defaults to the current working directory); the a/ and b/ prefixes of git diffs are stripped automatically unless strip is given
+- Hunks that moved are located by searching around their recorded position; fuzz allows up to that many context lines to be ignored at either end of a hunk
+- The patch is all-or-nothing: if any hunk of any file fails, no file is changed
+- Set check: true to validate the patch without writing anything
+- Returns a per-file, per-hunk report of what applied, with offsets and fuzz used`
+)
+
+type ApplyPatchInput struct {
+	Patch   string `json:"patch" jsonschema:"required" jsonschema_description:"The unified diff to apply."`
+	BaseDir string `json:"base_dir,omitempty" jsonschema_description:"Directory that paths in the patch are relative to. Defaults to the current working directory."`
+	Strip   *int   `json:"strip,omitempty" jsonschema_description:"Number of leading path components to strip from file names (like patch -p). Defaults to stripping git's a/ and b/ prefixes."`
+	Fuzz    int    `json:"fuzz,omitempty" jsonschema_description:"Maximum number of context lines that may be ignored at each end of a hunk (default 0)."`
+	Check   bool   `json:"check,omitempty" jsonschema_description:"Validate the patch without modifying any file (dry run)."`
+}
+
+type ApplyPatchHunkResult struct {
+	Index   int    `json:"index"`
+	Applied bool   `json:"applied"`
+	Offset  int    `json:"offset"`
+	Fuzz    int    `json:"fuzz"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ApplyPatchFileResult struct {
+	Path      string                 `json:"path"`
+	OldPath   string                 `json:"old_path,omitempty"`
+	Operation string                 `json:"operation"`
+	Success   bool                   `json:"success"`
+	Hunks     []ApplyPatchHunkResult `json:"hunks"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+type ApplyPatchOutput struct {
+	Success bool                   `json:"success"`
+	Applied bool                   `json:"applied"`
+	Files   []ApplyPatchFileResult `json:"files"`
+	Message string                 `json:"message"`
+}
+
+func NewApplyPatchTool(runner *runners.FileRunner, guard *WorkspaceGuard, tracker *FileStateTracker) *ToolDefinition[ApplyPatchInput, ApplyPatchOutput] {
+	return NewToolDefinition(
+		ApplyPatchToolName,
+		ApplyPatchToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ApplyPatchInput) (*mcp.CallToolResult, ApplyPatchOutput, error) {
+			baseDir := input.BaseDir
+			if baseDir == "" {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return nil, ApplyPatchOutput{}, fmt.Errorf("failed to get current directory: %w", err)
+				}
+				baseDir = cwd
+			}
+
+			baseDir, err := guard.Resolve(ctx, req, baseDir)
+			if err != nil {
+				return nil, ApplyPatchOutput{}, err
+			}
+
+			strip := -1
+			if input.Strip != nil {
+				strip = *input.Strip
+			}
+
+			result, err := runner.ApplyPatch(ctx, runners.ApplyPatchInput{
+				Patch: input.Patch,
+				Strip: strip,
+				Fuzz:  input.Fuzz,
+				Check: input.Check,
+				Resolve: func(path string) (string, error) {
+					if !filepath.IsAbs(path) {
+						path = filepath.Join(baseDir, path)
+					}
+					resolved, err := guard.Resolve(ctx, req, path)
+					if err == nil {
+						audit.TrackFiles(ctx, resolved)
+					}
+					return resolved, err
+				},
+				Verify: func(path string, modTime time.Time, content []byte) error {
+					return tracker.Check(req, path, modTime, content)
+				},
+			})
+			if err != nil {
+				return nil, ApplyPatchOutput{}, err
+			}
+
+			output := ApplyPatchOutput{
+				Success: true,
+				Applied: result.Applied,
+				Files:   make([]ApplyPatchFileResult, len(result.Files)),
+			}
+
+			for i, file := range result.Files {
+				hunks := make([]ApplyPatchHunkResult, len(file.Hunks))
+				for j, hunk := range file.Hunks {
+					hunks[j] = ApplyPatchHunkResult{
+						Index:   hunk.Index,
+						Applied: hunk.Applied,
+						Offset:  hunk.Offset,
+						Fuzz:    hunk.Fuzz,
+						Error:   hunk.Error,
+					}
+				}
+				output.Files[i] = ApplyPatchFileResult{
+					Path:      file.Path,
+					OldPath:   file.OldPath,
+					Operation: file.Operation,
+					Success:   file.Error == "",
+					Hunks:     hunks,
+					Error:     file.Error,
+				}
+				if file.Error != "" {
+					output.Success = false
+				}
+
+				if result.Applied && file.Operation != "delete" {
+					if info, err := os.Stat(file.Path); err == nil {
+						if content, err := os.ReadFile(file.Path); err == nil {
+							tracker.Record(req, file.Path, info.ModTime(), content)
+						}
+					}
+				}
+			}
+
+			switch {
+			case !output.Success:
+				output.Message = fmt.Sprintf("Patch does not apply; no files were changed (%d file(s) in patch)", len(output.Files))
+			case input.Check:
+				output.Message = fmt.Sprintf("Patch applies cleanly to %d file(s) (check only, no files were changed)", len(output.Files))
+			default:
+				output.Message = fmt.Sprintf("Successfully applied patch to %d file(s)", len(output.Files))
+			}
+
+			return &mcp.CallToolResult{
+				IsError: !output.Success,
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: formatApplyPatchSummary(output)},
+				},
+			}, output, nil
+		},
+	)
+}
+
+func formatApplyPatchSummary(output ApplyPatchOutput) string {
+	var builder strings.Builder
+	builder.WriteString(output.Message)
+
+	for _, file := range output.Files {
+		status := "ok"
+		if !file.Success {
+			status = "FAILED"
+		}
+		path := file.Path
+		if file.OldPath != "" {
+			path = file.OldPath + " -> " + file.Path
+		}
+		builder.WriteString(fmt.Sprintf("\n%s %s [%s]", file.Operation, path, status))
+		if file.Error != "" {
+			builder.WriteString(": " + file.Error)
+		}
+
+		for _, hunk := range file.Hunks {
+			if hunk.Applied {
+				builder.WriteString(fmt.Sprintf("\n  hunk #%d applied", hunk.Index+1))
+				if hunk.Offset != 0 {
+					builder.WriteString(fmt.Sprintf(" with offset %d", hunk.Offset))
+				}
+				if hunk.Fuzz != 0 {
+					builder.WriteString(fmt.Sprintf(" with fuzz %d", hunk.Fuzz))
+				}
+			} else {
+				builder.WriteString(fmt.Sprintf("\n  hunk #%d failed: %s", hunk.Index+1, hunk.Error))
+			}
+		}
+	}
+
+	return builder.String()
+}
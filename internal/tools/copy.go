@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -24,12 +25,19 @@ type CopyOutput struct {
 	Message string `json:"message"`
 }
 
-func NewCopyTool(runner *runners.FileRunner) *ToolDefinition[CopyInput, CopyOutput] {
+func NewCopyTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[CopyInput, CopyOutput] {
 	return NewToolDefinition(
 		CopyToolName,
 		CopyToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input CopyInput) (*mcp.CallToolResult, CopyOutput, error) {
-			if err := runner.Copy(ctx, runners.CopyInput{Source: input.Source, Target: input.Target, Overwrite: input.Overwrite}); err != nil {
+			paths, err := guard.ResolveAll(ctx, req, []string{input.Source, input.Target})
+			if err != nil {
+				return nil, CopyOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, paths[1])
+
+			if err := runner.Copy(ctx, runners.CopyInput{Source: paths[0], Target: paths[1], Overwrite: input.Overwrite}); err != nil {
 				return nil, CopyOutput{}, err
 			}
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -22,12 +23,19 @@ type DeleteOutput struct {
 	Message string `json:"message"`
 }
 
-func NewDeleteTool(runner *runners.FileRunner) *ToolDefinition[DeleteInput, DeleteOutput] {
+func NewDeleteTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[DeleteInput, DeleteOutput] {
 	return NewToolDefinition(
 		DeleteToolName,
 		DeleteToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input DeleteInput) (*mcp.CallToolResult, DeleteOutput, error) {
-			if err := runner.Delete(ctx, runners.DeleteInput{Path: input.Path}); err != nil {
+			path, err := guard.Resolve(ctx, req, input.Path)
+			if err != nil {
+				return nil, DeleteOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			if err := runner.Delete(ctx, runners.DeleteInput{Path: path}); err != nil {
 				return nil, DeleteOutput{}, err
 			}
 
@@ -6,6 +6,8 @@ import (
 	"os"
 	"strings"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -34,13 +36,20 @@ type EditOutput struct {
 	Message       string `json:"message"`
 }
 
-func NewEditTool() *ToolDefinition[EditInput, EditOutput] {
+func NewEditTool(guard *WorkspaceGuard, tracker *FileStateTracker) *ToolDefinition[EditInput, EditOutput] {
 	return NewToolDefinition(
 		EditToolName,
 		EditToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input EditInput) (*mcp.CallToolResult, EditOutput, error) {
 
-			content, err := os.ReadFile(input.FilePath)
+			path, err := guard.Resolve(ctx, req, input.FilePath)
+			if err != nil {
+				return nil, EditOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			info, err := os.Stat(path)
 			if err != nil {
 				if os.IsNotExist(err) {
 					return nil, EditOutput{}, fmt.Errorf("file does not exist: %s", input.FilePath)
@@ -48,39 +57,39 @@ func NewEditTool() *ToolDefinition[EditInput, EditOutput] {
 				return nil, EditOutput{}, fmt.Errorf("failed to read file: %w", err)
 			}
 
-			fileContent := string(content)
-
-			if !strings.Contains(fileContent, input.OldString) {
-				return nil, EditOutput{}, fmt.Errorf("old_string not found in file")
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, EditOutput{}, fmt.Errorf("failed to read file: %w", err)
 			}
 
-			if input.OldString == input.NewString {
-				return nil, EditOutput{}, fmt.Errorf("old_string and new_string must be different")
+			if err := tracker.Check(req, path, info.ModTime(), content); err != nil {
+				return nil, EditOutput{}, err
 			}
 
-			var newContent string
-			var replacedCount int
-
-			if input.ReplaceAll {
-
-				replacedCount = strings.Count(fileContent, input.OldString)
-				newContent = strings.ReplaceAll(fileContent, input.OldString, input.NewString)
-			} else {
+			text, format := runners.DecodeText(content)
 
-				count := strings.Count(fileContent, input.OldString)
-				if count > 1 {
-					return nil, EditOutput{}, fmt.Errorf("old_string appears %d times in the file. Either provide more context to make it unique or use replace_all=true", count)
-				}
+			newText, replacedCount, err := applyEdit(text,
+				runners.NormalizeLineEndings(input.OldString, format.LineEnding),
+				runners.NormalizeLineEndings(input.NewString, format.LineEnding),
+				input.ReplaceAll)
+			if err != nil {
+				return nil, EditOutput{}, err
+			}
 
-				newContent = strings.Replace(fileContent, input.OldString, input.NewString, 1)
-				replacedCount = 1
+			newContent, err := runners.EncodeText(newText, format)
+			if err != nil {
+				return nil, EditOutput{}, fmt.Errorf("failed to encode file as %s: %w", format.Encoding, err)
 			}
 
-			err = os.WriteFile(input.FilePath, []byte(newContent), 0644)
+			err = runners.WriteFileAtomic(path, newContent, 0644)
 			if err != nil {
 				return nil, EditOutput{}, fmt.Errorf("failed to write file: %w", err)
 			}
 
+			if info, err := os.Stat(path); err == nil {
+				tracker.Record(req, path, info.ModTime(), newContent)
+			}
+
 			output := EditOutput{
 				Success:       true,
 				ReplacedCount: replacedCount,
@@ -95,3 +104,24 @@ func NewEditTool() *ToolDefinition[EditInput, EditOutput] {
 		},
 	)
 }
+
+func applyEdit(content, oldString, newString string, replaceAll bool) (string, int, error) {
+	if !strings.Contains(content, oldString) {
+		return "", 0, fmt.Errorf("old_string not found in file")
+	}
+
+	if oldString == newString {
+		return "", 0, fmt.Errorf("old_string and new_string must be different")
+	}
+
+	if replaceAll {
+		return strings.ReplaceAll(content, oldString, newString), strings.Count(content, oldString), nil
+	}
+
+	count := strings.Count(content, oldString)
+	if count > 1 {
+		return "", 0, fmt.Errorf("old_string appears %d times in the file. Either provide more context to make it unique or use replace_all=true", count)
+	}
+
+	return strings.Replace(content, oldString, newString, 1), 1, nil
+}
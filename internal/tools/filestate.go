@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type fileSnapshot struct {
+	ModTime time.Time
+	Hash    string
+}
+
+type FileStateTracker struct {
+	mu       sync.Mutex
+	sessions map[*mcp.ServerSession]map[string]fileSnapshot
+}
+
+func NewFileStateTracker() *FileStateTracker {
+	return &FileStateTracker{
+		sessions: make(map[*mcp.ServerSession]map[string]fileSnapshot),
+	}
+}
+
+func (t *FileStateTracker) Record(req *mcp.CallToolRequest, path string, modTime time.Time, content []byte) {
+	session := sessionOf(req)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	files, ok := t.sessions[session]
+	if !ok {
+		files = make(map[string]fileSnapshot)
+		t.sessions[session] = files
+		onSessionClose(session, func() {
+			t.mu.Lock()
+			delete(t.sessions, session)
+			t.mu.Unlock()
+		})
+	}
+	files[path] = fileSnapshot{ModTime: modTime, Hash: hashContent(content)}
+}
+
+// Check verifies that path was served by the read tool in this session and
+// that content, as currently on disk, still matches what was read.
+func (t *FileStateTracker) Check(req *mcp.CallToolRequest, path string, modTime time.Time, content []byte) error {
+	session := sessionOf(req)
+
+	t.mu.Lock()
+	snapshot, ok := t.sessions[session][path]
+	t.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("file has not been read yet: %s. Use the read tool before modifying it", path)
+	}
+
+	if snapshot.Hash != hashContent(content) {
+		return fmt.Errorf("file has been modified since it was last read: %s (read at mtime %s, now %s). Read it again before modifying it",
+			path, snapshot.ModTime.Format(time.RFC3339Nano), modTime.Format(time.RFC3339Nano))
+	}
+
+	return nil
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
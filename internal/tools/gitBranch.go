@@ -28,11 +28,15 @@ type GitBranchOutput struct {
 	Branches string `json:"branches"`
 }
 
-func NewGitBranchTool(runner *runners.GitRunner) *ToolDefinition[GitBranchInput, GitBranchOutput] {
+func NewGitBranchTool(runner *runners.GitRunner, guard *WorkspaceGuard) *ToolDefinition[GitBranchInput, GitBranchOutput] {
 	return NewToolDefinition(
 		GitBranchToolName,
 		GitBranchToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input GitBranchInput) (*mcp.CallToolResult, GitBranchOutput, error) {
+			if _, err := guard.Resolve(ctx, req, "."); err != nil {
+				return nil, GitBranchOutput{}, err
+			}
+
 			result, err := runner.Branch(ctx, runners.GitBranchInput{
 				All:      input.All,
 				Remotes:  input.Remotes,
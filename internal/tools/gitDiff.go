@@ -30,15 +30,20 @@ type GitDiffOutput struct {
 	Diff string `json:"diff"`
 }
 
-func NewGitDiffTool(runner *runners.GitRunner) *ToolDefinition[GitDiffInput, GitDiffOutput] {
+func NewGitDiffTool(runner *runners.GitRunner, guard *WorkspaceGuard) *ToolDefinition[GitDiffInput, GitDiffOutput] {
 	return NewToolDefinition(
 		GitDiffToolName,
 		GitDiffToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input GitDiffInput) (*mcp.CallToolResult, GitDiffOutput, error) {
+			paths, err := guard.ResolveAll(ctx, req, append([]string{"."}, input.Paths...))
+			if err != nil {
+				return nil, GitDiffOutput{}, err
+			}
+
 			result, err := runner.Diff(ctx, runners.GitDiffInput{
 				Base:     input.Base,
 				Target:   input.Target,
-				Paths:    input.Paths,
+				Paths:    paths[1:],
 				Staged:   input.Staged,
 				NameOnly: input.NameOnly,
 			})
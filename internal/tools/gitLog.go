@@ -29,11 +29,15 @@ type GitLogOutput struct {
 	Log string `json:"log"`
 }
 
-func NewGitLogTool(runner *runners.GitRunner) *ToolDefinition[GitLogInput, GitLogOutput] {
+func NewGitLogTool(runner *runners.GitRunner, guard *WorkspaceGuard) *ToolDefinition[GitLogInput, GitLogOutput] {
 	return NewToolDefinition(
 		GitLogToolName,
 		GitLogToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input GitLogInput) (*mcp.CallToolResult, GitLogOutput, error) {
+			if _, err := guard.Resolve(ctx, req, "."); err != nil {
+				return nil, GitLogOutput{}, err
+			}
+
 			result, err := runner.Log(ctx, runners.GitLogInput{
 				Oneline: input.Oneline,
 				Limit:   input.Limit,
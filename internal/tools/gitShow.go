@@ -30,14 +30,27 @@ type GitShowOutput struct {
 	Content string `json:"content"`
 }
 
-func NewGitShowTool(runner *runners.GitRunner) *ToolDefinition[GitShowInput, GitShowOutput] {
+func NewGitShowTool(runner *runners.GitRunner, guard *WorkspaceGuard) *ToolDefinition[GitShowInput, GitShowOutput] {
 	return NewToolDefinition(
 		GitShowToolName,
 		GitShowToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input GitShowInput) (*mcp.CallToolResult, GitShowOutput, error) {
+			if _, err := guard.Resolve(ctx, req, "."); err != nil {
+				return nil, GitShowOutput{}, err
+			}
+
+			path := input.Path
+			if path != "" {
+				resolved, err := guard.Resolve(ctx, req, path)
+				if err != nil {
+					return nil, GitShowOutput{}, err
+				}
+				path = resolved
+			}
+
 			result, err := runner.Show(ctx, runners.GitShowInput{
 				Ref:      input.Ref,
-				Path:     input.Path,
+				Path:     path,
 				Format:   input.Format,
 				NameOnly: input.NameOnly,
 				Stat:     input.Stat,
@@ -25,11 +25,15 @@ type GitStatusOutput struct {
 	Status string `json:"status"`
 }
 
-func NewGitStatusTool(runner *runners.GitRunner) *ToolDefinition[GitStatusInput, GitStatusOutput] {
+func NewGitStatusTool(runner *runners.GitRunner, guard *WorkspaceGuard) *ToolDefinition[GitStatusInput, GitStatusOutput] {
 	return NewToolDefinition(
 		GitStatusToolName,
 		GitStatusToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input GitStatusInput) (*mcp.CallToolResult, GitStatusOutput, error) {
+			if _, err := guard.Resolve(ctx, req, "."); err != nil {
+				return nil, GitStatusOutput{}, err
+			}
+
 			result, err := runner.Status(ctx, runners.GitStatusInput{Short: input.Short})
 			if err != nil {
 				return nil, GitStatusOutput{}, err
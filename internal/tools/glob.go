@@ -34,7 +34,7 @@ type fileInfo struct {
 	modTime time.Time
 }
 
-func NewGlobTool() *ToolDefinition[GlobInput, GlobOutput] {
+func NewGlobTool(guard *WorkspaceGuard) *ToolDefinition[GlobInput, GlobOutput] {
 	return NewToolDefinition(
 		GlobToolName,
 		GlobToolDescription,
@@ -48,9 +48,9 @@ func NewGlobTool() *ToolDefinition[GlobInput, GlobOutput] {
 				searchPath = cwd
 			}
 
-			absPath, err := filepath.Abs(searchPath)
+			absPath, err := guard.Resolve(ctx, req, searchPath)
 			if err != nil {
-				return nil, GlobOutput{}, fmt.Errorf("failed to get absolute path: %w", err)
+				return nil, GlobOutput{}, err
 			}
 
 			fullPattern := filepath.Join(absPath, input.Pattern)
@@ -69,6 +69,9 @@ func NewGlobTool() *ToolDefinition[GlobInput, GlobOutput] {
 
 			fileInfos := make([]fileInfo, 0, len(matches))
 			for _, match := range matches {
+				if _, err := guard.Resolve(ctx, req, match); err != nil {
+					continue
+				}
 				info, err := os.Stat(match)
 				if err != nil {
 					continue
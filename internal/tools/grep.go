@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"path/filepath"
 
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -17,7 +18,9 @@ Usage:
 - Filter files with glob parameter (e.g., "*.js", "**/*.tsx") or type parameter (e.g., "js", "py", "rust")
 - Output modes: "content" shows matching lines, "files_with_matches" shows only file paths (default), "count" shows match counts
 - Pattern syntax: Uses ripgrep (not grep) - literal braces need escaping (use 'interface\\{\\}' to find 'interface{}' in Go code)
-- Multiline matching: By default patterns match within single lines only. For cross-line patterns like 'struct \\{[\\s\\S]*?field', use multiline: true`
+- Multiline matching: By default patterns match within single lines only. For cross-line patterns like 'struct \\{[\\s\\S]*?field', use multiline: true
+- Paths are reported relative to the workspace root
+- Structured output lists the matching files; "count" mode adds each file's matched line count, and "content" mode its matched line and match counts and its matches with line number, 1-based byte column, byte offset of the line, submatches and context lines`
 )
 
 type GrepInput struct {
@@ -36,10 +39,11 @@ type GrepInput struct {
 }
 
 type GrepOutput struct {
-	Result string `json:"result"`
+	Files     []runners.RipgrepFile `json:"files"`
+	Truncated bool                  `json:"truncated,omitempty"`
 }
 
-func NewGrepTool(runner *runners.RipgrepRunner) *ToolDefinition[GrepInput, GrepOutput] {
+func NewGrepTool(runner *runners.RipgrepRunner, guard *WorkspaceGuard) *ToolDefinition[GrepInput, GrepOutput] {
 	return NewToolDefinition(
 		GrepToolName,
 		GrepToolDescription,
@@ -48,9 +52,32 @@ func NewGrepTool(runner *runners.RipgrepRunner) *ToolDefinition[GrepInput, GrepO
 				input.OutputMode = "files_with_matches"
 			}
 
+			searchPath := input.Path
+			if searchPath == "" {
+				searchPath = "."
+			}
+
+			path, err := guard.Resolve(ctx, req, searchPath)
+			if err != nil {
+				return nil, GrepOutput{}, err
+			}
+
+			// Run rg from the workspace root so it reports paths relative
+			// to it.
+			dir := guard.RootOf(path)
+			if dir != "" {
+				if rel, err := filepath.Rel(dir, path); err == nil {
+					path = rel
+					if rel == "." {
+						path = ""
+					}
+				}
+			}
+
 			result, err := runner.Search(ctx, runners.RipgrepSearchInput{
 				Pattern:         input.Pattern,
-				Path:            input.Path,
+				Dir:             dir,
+				Path:            path,
 				Glob:            input.Glob,
 				Type:            input.Type,
 				CaseInsensitive: input.CaseInsensitive,
@@ -67,10 +94,14 @@ func NewGrepTool(runner *runners.RipgrepRunner) *ToolDefinition[GrepInput, GrepO
 				return nil, GrepOutput{}, err
 			}
 
-			output := GrepOutput{Result: result}
+			output := GrepOutput{Files: result.Files, Truncated: result.Truncated}
+			if output.Files == nil {
+				output.Files = []runners.RipgrepFile{}
+			}
+
 			return &mcp.CallToolResult{
 				Content: []mcp.Content{
-					&mcp.TextContent{Text: result},
+					&mcp.TextContent{Text: result.Text},
 				},
 			}, output, nil
 		},
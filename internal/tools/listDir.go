@@ -41,13 +41,18 @@ type ListDirOutput struct {
 	Entries []ListDirEntry `json:"entries"`
 }
 
-func NewListDirTool(runner *runners.FileRunner) *ToolDefinition[ListDirInput, ListDirOutput] {
+func NewListDirTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[ListDirInput, ListDirOutput] {
 	return NewToolDefinition(
 		ListDirToolName,
 		ListDirToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input ListDirInput) (*mcp.CallToolResult, ListDirOutput, error) {
+			path, err := guard.Resolve(ctx, req, input.Path)
+			if err != nil {
+				return nil, ListDirOutput{}, err
+			}
+
 			entries, err := runner.ListDir(ctx, runners.ListDirInput{
-				Path:       input.Path,
+				Path:       path,
 				Recursive:  input.Recursive,
 				ShowHidden: input.ShowHidden,
 				Limit:      input.Limit,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -24,12 +25,23 @@ type MoveOutput struct {
 	Message string `json:"message"`
 }
 
-func NewMoveTool(runner *runners.FileRunner) *ToolDefinition[MoveInput, MoveOutput] {
+func NewMoveTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[MoveInput, MoveOutput] {
 	return NewToolDefinition(
 		MoveToolName,
 		MoveToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input MoveInput) (*mcp.CallToolResult, MoveOutput, error) {
-			if err := runner.Move(ctx, runners.MoveInput{Source: input.Source, Target: input.Target, Overwrite: input.Overwrite}); err != nil {
+			paths, err := guard.ResolveAll(ctx, req, []string{input.Source, input.Target})
+			if err != nil {
+				return nil, MoveOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, paths[0], paths[1])
+
+			if guard.IsRoot(ctx, req, paths[0]) {
+				return nil, MoveOutput{}, fmt.Errorf("refusing to move workspace root: %s", paths[0])
+			}
+
+			if err := runner.Move(ctx, runners.MoveInput{Source: paths[0], Target: paths[1], Overwrite: input.Overwrite}); err != nil {
 				return nil, MoveOutput{}, err
 			}
 
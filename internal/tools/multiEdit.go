@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	MultiEditToolName        = "multi_edit"
+	MultiEditToolDescription = `Performs multiple exact string replacements in a single file in one atomic operation.
+
+Usage:
+- You must use your Read tool at least once in the conversation before editing. This tool will error if you attempt an edit without reading the file.
+- Edits are applied in order, each one against the result of the previous edit
+- Every edit follows the same rules as the edit tool: old_string must be unique unless replace_all is set, and new_string must differ from old_string
+- The file is written only if ALL edits succeed; if any edit fails, none are applied
+- Plan edits carefully so that earlier edits do not change the text later edits are trying to match`
+)
+
+type MultiEditOperation struct {
+	OldString  string `json:"old_string" jsonschema:"required" jsonschema_description:"The text to replace"`
+	NewString  string `json:"new_string" jsonschema:"required" jsonschema_description:"The text to replace it with (must be different from old_string)"`
+	ReplaceAll bool   `json:"replace_all,omitempty" jsonschema_description:"Replace all occurences of old_string (default false)" jsonschema_default:"false"`
+}
+
+type MultiEditInput struct {
+	FilePath string               `json:"file_path" jsonschema:"required" jsonschema_description:"The absolute path to the file to modify"`
+	Edits    []MultiEditOperation `json:"edits" jsonschema:"required" jsonschema_description:"Ordered list of edit operations to apply to the file"`
+}
+
+type MultiEditResult struct {
+	Index         int `json:"index"`
+	ReplacedCount int `json:"replaced_count"`
+}
+
+type MultiEditOutput struct {
+	Success       bool              `json:"success"`
+	Edits         []MultiEditResult `json:"edits"`
+	ReplacedCount int               `json:"replaced_count"`
+	Message       string            `json:"message"`
+}
+
+func NewMultiEditTool(guard *WorkspaceGuard, tracker *FileStateTracker) *ToolDefinition[MultiEditInput, MultiEditOutput] {
+	return NewToolDefinition(
+		MultiEditToolName,
+		MultiEditToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input MultiEditInput) (*mcp.CallToolResult, MultiEditOutput, error) {
+			if len(input.Edits) == 0 {
+				return nil, MultiEditOutput{}, fmt.Errorf("at least one edit is required")
+			}
+
+			path, err := guard.Resolve(ctx, req, input.FilePath)
+			if err != nil {
+				return nil, MultiEditOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			info, err := os.Stat(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, MultiEditOutput{}, fmt.Errorf("file does not exist: %s", input.FilePath)
+				}
+				return nil, MultiEditOutput{}, fmt.Errorf("failed to read file: %w", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, MultiEditOutput{}, fmt.Errorf("failed to read file: %w", err)
+			}
+
+			if err := tracker.Check(req, path, info.ModTime(), content); err != nil {
+				return nil, MultiEditOutput{}, err
+			}
+
+			newText, format := runners.DecodeText(content)
+			results := make([]MultiEditResult, 0, len(input.Edits))
+			total := 0
+
+			for i, edit := range input.Edits {
+				var replacedCount int
+				newText, replacedCount, err = applyEdit(newText,
+					runners.NormalizeLineEndings(edit.OldString, format.LineEnding),
+					runners.NormalizeLineEndings(edit.NewString, format.LineEnding),
+					edit.ReplaceAll)
+				if err != nil {
+					return nil, MultiEditOutput{}, fmt.Errorf("edit %d of %d failed, no changes were written: %w", i+1, len(input.Edits), err)
+				}
+				results = append(results, MultiEditResult{Index: i, ReplacedCount: replacedCount})
+				total += replacedCount
+			}
+
+			newContent, err := runners.EncodeText(newText, format)
+			if err != nil {
+				return nil, MultiEditOutput{}, fmt.Errorf("failed to encode file as %s: %w", format.Encoding, err)
+			}
+
+			err = runners.WriteFileAtomic(path, newContent, 0644)
+			if err != nil {
+				return nil, MultiEditOutput{}, fmt.Errorf("failed to write file: %w", err)
+			}
+
+			if info, err := os.Stat(path); err == nil {
+				tracker.Record(req, path, info.ModTime(), newContent)
+			}
+
+			output := MultiEditOutput{
+				Success:       true,
+				Edits:         results,
+				ReplacedCount: total,
+				Message:       fmt.Sprintf("Successfully applied %d edit(s), replacing %d occurrence(s)", len(results), total),
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: formatMultiEditSummary(output)},
+				},
+			}, output, nil
+		},
+	)
+}
+
+func formatMultiEditSummary(output MultiEditOutput) string {
+	var builder strings.Builder
+	builder.WriteString(output.Message)
+	for _, result := range output.Edits {
+		builder.WriteString(fmt.Sprintf("\n  edit %d: replaced %d occurrence(s)", result.Index+1, result.ReplacedCount))
+	}
+	return builder.String()
+}
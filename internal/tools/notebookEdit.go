@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	NotebookEditToolName        = "notebook_edit"
+	NotebookEditToolDescription = `Replaces, inserts or deletes a single cell in a Jupyter notebook (.ipynb file).
+
+Usage:
+- You must use your Read tool on the notebook before editing it. This tool will error if you attempt an edit without reading the file.
+- Cells are addressed by the id shown by the Read tool (cells without an id are addressed as cell-N, N being the zero-based index)
+- edit_mode "replace" (default) replaces the source of cell_id, optionally changing its cell_type
+- edit_mode "insert" adds a new cell after cell_id, or at the top of the notebook when cell_id is omitted; cell_type defaults to code
+- edit_mode "delete" removes cell_id
+- The rest of the notebook JSON, including outputs and metadata, is left untouched`
+)
+
+type NotebookEditInput struct {
+	NotebookPath string `json:"notebook_path" jsonschema:"required" jsonschema_description:"The absolute path to the Jupyter notebook to modify"`
+	CellID       string `json:"cell_id,omitempty" jsonschema_description:"The id of the cell to edit. For insert, the new cell goes after this cell (or at the top when omitted)."`
+	NewSource    string `json:"new_source,omitempty" jsonschema_description:"The new source for the cell. Required for replace and insert."`
+	CellType     string `json:"cell_type,omitempty" jsonschema_description:"The cell type: \"code\", \"markdown\" or \"raw\". Defaults to code for insert and to the current type for replace."`
+	EditMode     string `json:"edit_mode,omitempty" jsonschema_description:"The edit to perform: \"replace\" (default), \"insert\" or \"delete\"."`
+}
+
+type NotebookEditOutput struct {
+	Success   bool   `json:"success"`
+	CellID    string `json:"cell_id"`
+	EditMode  string `json:"edit_mode"`
+	CellCount int    `json:"cell_count"`
+	Message   string `json:"message"`
+}
+
+func NewNotebookEditTool(guard *WorkspaceGuard, tracker *FileStateTracker) *ToolDefinition[NotebookEditInput, NotebookEditOutput] {
+	return NewToolDefinition(
+		NotebookEditToolName,
+		NotebookEditToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input NotebookEditInput) (*mcp.CallToolResult, NotebookEditOutput, error) {
+			path, err := guard.Resolve(ctx, req, input.NotebookPath)
+			if err != nil {
+				return nil, NotebookEditOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			info, err := os.Stat(path)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil, NotebookEditOutput{}, fmt.Errorf("notebook does not exist: %s", input.NotebookPath)
+				}
+				return nil, NotebookEditOutput{}, fmt.Errorf("failed to read notebook: %w", err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return nil, NotebookEditOutput{}, fmt.Errorf("failed to read notebook: %w", err)
+			}
+
+			if err := tracker.Check(req, path, info.ModTime(), content); err != nil {
+				return nil, NotebookEditOutput{}, err
+			}
+
+			notebook, err := runners.ParseNotebook(content)
+			if err != nil {
+				return nil, NotebookEditOutput{}, err
+			}
+
+			mode := input.EditMode
+			if mode == "" {
+				mode = "replace"
+			}
+
+			cellID, err := notebook.Apply(runners.NotebookEdit{
+				CellID:   input.CellID,
+				Source:   input.NewSource,
+				CellType: input.CellType,
+				Mode:     mode,
+			})
+			if err != nil {
+				return nil, NotebookEditOutput{}, err
+			}
+
+			newContent, err := notebook.Marshal()
+			if err != nil {
+				return nil, NotebookEditOutput{}, fmt.Errorf("failed to encode notebook: %w", err)
+			}
+
+			if err := runners.WriteFileAtomic(path, newContent, 0644); err != nil {
+				return nil, NotebookEditOutput{}, fmt.Errorf("failed to write notebook: %w", err)
+			}
+
+			if info, err := os.Stat(path); err == nil {
+				tracker.Record(req, path, info.ModTime(), newContent)
+			}
+
+			var message string
+			switch mode {
+			case "insert":
+				message = fmt.Sprintf("Inserted cell %s", cellID)
+			case "delete":
+				message = fmt.Sprintf("Deleted cell %s", cellID)
+			default:
+				message = fmt.Sprintf("Replaced cell %s", cellID)
+			}
+
+			output := NotebookEditOutput{
+				Success:   true,
+				CellID:    cellID,
+				EditMode:  mode,
+				CellCount: len(notebook.Cells()),
+				Message:   message,
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: message},
+				},
+			}, output, nil
+		},
+	)
+}
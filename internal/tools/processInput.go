@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ProcessInputToolName        = "process_input"
+	ProcessInputToolDescription = `Types input into a background process started with run (background: true, pty: true).
+
+Usage:
+- input is written to the process's terminal as-is; include "\n" to press enter
+- keys are named keys sent after input, in order: enter, tab, escape, backspace, space, up, down, left, right, home, end, delete, pageup, pagedown, ctrl-a to ctrl-z
+- Read the process's response with process_output`
+)
+
+// namedKeys maps key names to what a terminal sends for them.
+var namedKeys = map[string]string{
+	"enter":     "\r",
+	"tab":       "\t",
+	"escape":    "\x1b",
+	"backspace": "\x7f",
+	"space":     " ",
+	"up":        "\x1b[A",
+	"down":      "\x1b[B",
+	"right":     "\x1b[C",
+	"left":      "\x1b[D",
+	"home":      "\x1b[H",
+	"end":       "\x1b[F",
+	"delete":    "\x1b[3~",
+	"pageup":    "\x1b[5~",
+	"pagedown":  "\x1b[6~",
+}
+
+type ProcessInputInput struct {
+	ProcessID string   `json:"process_id" jsonschema:"required" jsonschema_description:"Id returned by run with background: true and pty: true."`
+	Input     string   `json:"input,omitempty" jsonschema_description:"Text to type into the terminal."`
+	Keys      []string `json:"keys,omitempty" jsonschema_description:"Named keys to press after input, e.g. [\"enter\"], [\"down\", \"enter\"] or [\"ctrl-c\"]."`
+}
+
+type ProcessInputOutput struct {
+	Success      bool   `json:"success"`
+	BytesWritten int    `json:"bytes_written"`
+	Message      string `json:"message"`
+}
+
+func NewProcessInputTool(processes *BackgroundProcesses) *ToolDefinition[ProcessInputInput, ProcessInputOutput] {
+	return NewToolDefinition(
+		ProcessInputToolName,
+		ProcessInputToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ProcessInputInput) (*mcp.CallToolResult, ProcessInputOutput, error) {
+			if input.ProcessID == "" {
+				return nil, ProcessInputOutput{}, fmt.Errorf("process_id is required")
+			}
+
+			data := input.Input
+			for _, key := range input.Keys {
+				sequence, err := keySequence(key)
+				if err != nil {
+					return nil, ProcessInputOutput{}, err
+				}
+				data += sequence
+			}
+			if data == "" {
+				return nil, ProcessInputOutput{}, fmt.Errorf("input or keys is required")
+			}
+
+			if err := processes.Manager().Input(input.ProcessID, processes.Owner(req), []byte(data)); err != nil {
+				return nil, ProcessInputOutput{}, err
+			}
+
+			message := fmt.Sprintf("Wrote %d bytes to process %s", len(data), input.ProcessID)
+			output := ProcessInputOutput{Success: true, BytesWritten: len(data), Message: message}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: message},
+				},
+			}, output, nil
+		},
+	)
+}
+
+func keySequence(key string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(key))
+	if sequence, ok := namedKeys[name]; ok {
+		return sequence, nil
+	}
+
+	// ctrl-<letter> sends the matching control character, e.g. ctrl-c is 0x03.
+	for _, prefix := range []string{"ctrl-", "ctrl+", "c-"} {
+		if letter, ok := strings.CutPrefix(name, prefix); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+			return string(rune(letter[0] - 'a' + 1)), nil
+		}
+	}
+
+	names := make([]string, 0, len(namedKeys))
+	for name := range namedKeys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown key: %s (expected one of %s, or ctrl-a to ctrl-z)", key, strings.Join(names, ", "))
+}
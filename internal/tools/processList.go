@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ProcessListToolName        = "process_list"
+	ProcessListToolDescription = `Lists background processes started with run (background: true) in this session.
+
+Usage:
+- Shows each process id, pid, command, status and exit code or signal once it has finished
+- Recently finished processes stay listed so their output can still be read with process_output`
+)
+
+type ProcessListInput struct{}
+
+type ProcessListOutput struct {
+	Processes []runners.ProcessInfo `json:"processes"`
+}
+
+func NewProcessListTool(processes *BackgroundProcesses) *ToolDefinition[ProcessListInput, ProcessListOutput] {
+	return NewToolDefinition(
+		ProcessListToolName,
+		ProcessListToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ProcessListInput) (*mcp.CallToolResult, ProcessListOutput, error) {
+			infos := processes.Manager().List(processes.Owner(req))
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: formatProcessList(infos)},
+				},
+			}, ProcessListOutput{Processes: infos}, nil
+		},
+	)
+}
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ProcessOutputToolName        = "process_output"
+	ProcessOutputToolDescription = `Reads output from a background process started with run (background: true).
+
+Usage:
+- Returns stdout and stderr written since the given cursors, plus the cursors to pass on the next call
+- Start both cursors at 0; pass back stdout_cursor and stderr_cursor to read only new output
+- Only the most recent output of each stream is buffered; stdout_dropped/stderr_dropped report bytes that were discarded before they could be read
+- The process status is included, so polling this tool also tells you when the process exits`
+)
+
+type ProcessOutputInput struct {
+	ProcessID    string `json:"process_id" jsonschema:"required" jsonschema_description:"Id returned by run with background: true."`
+	StdoutCursor int64  `json:"stdout_cursor,omitempty" jsonschema_description:"Byte offset in stdout to read from (0 for the beginning)."`
+	StderrCursor int64  `json:"stderr_cursor,omitempty" jsonschema_description:"Byte offset in stderr to read from (0 for the beginning)."`
+	MaxBytes     int    `json:"max_bytes,omitempty" jsonschema_description:"Maximum bytes to return per stream (default all buffered output)."`
+}
+
+type ProcessOutputOutput struct {
+	Process       runners.ProcessInfo `json:"process"`
+	Stdout        string              `json:"stdout"`
+	Stderr        string              `json:"stderr"`
+	StdoutCursor  int64               `json:"stdout_cursor"`
+	StderrCursor  int64               `json:"stderr_cursor"`
+	StdoutDropped int64               `json:"stdout_dropped,omitempty"`
+	StderrDropped int64               `json:"stderr_dropped,omitempty"`
+}
+
+func NewProcessOutputTool(processes *BackgroundProcesses) *ToolDefinition[ProcessOutputInput, ProcessOutputOutput] {
+	return NewToolDefinition(
+		ProcessOutputToolName,
+		ProcessOutputToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ProcessOutputInput) (*mcp.CallToolResult, ProcessOutputOutput, error) {
+			if input.ProcessID == "" {
+				return nil, ProcessOutputOutput{}, fmt.Errorf("process_id is required")
+			}
+			owner := processes.Owner(req)
+
+			// Status first: once it reports the process exited, the output
+			// read after it is complete.
+			info, err := processes.Manager().Info(input.ProcessID, owner)
+			if err != nil {
+				return nil, ProcessOutputOutput{}, err
+			}
+			result, err := processes.Manager().Output(input.ProcessID, owner, input.StdoutCursor, input.StderrCursor, input.MaxBytes)
+			if err != nil {
+				return nil, ProcessOutputOutput{}, err
+			}
+
+			output := ProcessOutputOutput{
+				Process:       info,
+				Stdout:        result.Stdout,
+				Stderr:        result.Stderr,
+				StdoutCursor:  result.StdoutCursor,
+				StderrCursor:  result.StderrCursor,
+				StdoutDropped: result.StdoutDropped,
+				StderrDropped: result.StderrDropped,
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: formatProcessOutput(output)},
+				},
+			}, output, nil
+		},
+	)
+}
+
+func formatProcessOutput(output ProcessOutputOutput) string {
+	var builder strings.Builder
+	builder.WriteString(formatProcessStatus(output.Process))
+	builder.WriteString(fmt.Sprintf("\nStdout (cursor %d):\n", output.StdoutCursor))
+	if output.StdoutDropped > 0 {
+		builder.WriteString(fmt.Sprintf("[%d bytes dropped]\n", output.StdoutDropped))
+	}
+	builder.WriteString(output.Stdout)
+	builder.WriteString(fmt.Sprintf("\nStderr (cursor %d):\n", output.StderrCursor))
+	if output.StderrDropped > 0 {
+		builder.WriteString(fmt.Sprintf("[%d bytes dropped]\n", output.StderrDropped))
+	}
+	builder.WriteString(output.Stderr)
+	return builder.String()
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ProcessSignalToolName        = "process_signal"
+	ProcessSignalToolDescription = `Sends a signal to a background process started with run (background: true).
+
+Usage:
+- signal defaults to TERM; names like INT, HUP, KILL (with or without the SIG prefix) and numbers are accepted
+- Use process_wait afterwards to confirm the process has exited`
+)
+
+type ProcessSignalInput struct {
+	ProcessID string `json:"process_id" jsonschema:"required" jsonschema_description:"Id returned by run with background: true."`
+	Signal    string `json:"signal,omitempty" jsonschema_description:"Signal to send (default TERM)."`
+}
+
+type ProcessSignalOutput struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+func NewProcessSignalTool(processes *BackgroundProcesses) *ToolDefinition[ProcessSignalInput, ProcessSignalOutput] {
+	return NewToolDefinition(
+		ProcessSignalToolName,
+		ProcessSignalToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ProcessSignalInput) (*mcp.CallToolResult, ProcessSignalOutput, error) {
+			if input.ProcessID == "" {
+				return nil, ProcessSignalOutput{}, fmt.Errorf("process_id is required")
+			}
+
+			signal := strings.ToUpper(input.Signal)
+			if signal == "" {
+				signal = "TERM"
+			}
+
+			if err := processes.Manager().Signal(input.ProcessID, processes.Owner(req), signal); err != nil {
+				return nil, ProcessSignalOutput{}, err
+			}
+
+			message := fmt.Sprintf("Sent %s to process %s", signal, input.ProcessID)
+			output := ProcessSignalOutput{Success: true, Message: message}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: message},
+				},
+			}, output, nil
+		},
+	)
+}
@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	ProcessWaitToolName        = "process_wait"
+	ProcessWaitToolDescription = `Waits for a background process started with run (background: true) to exit.
+
+Usage:
+- Returns as soon as the process exits, with its exit code or signal
+- timeout_seconds bounds the wait (default 30); timed_out is true if the process is still running
+- Read the remaining output with process_output`
+
+	defaultProcessWaitSeconds = 30
+)
+
+type ProcessWaitInput struct {
+	ProcessID      string `json:"process_id" jsonschema:"required" jsonschema_description:"Id returned by run with background: true."`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" jsonschema_description:"Maximum seconds to wait (default 30)."`
+}
+
+type ProcessWaitOutput struct {
+	Process  runners.ProcessInfo `json:"process"`
+	TimedOut bool                `json:"timed_out"`
+}
+
+func NewProcessWaitTool(processes *BackgroundProcesses) *ToolDefinition[ProcessWaitInput, ProcessWaitOutput] {
+	return NewToolDefinition(
+		ProcessWaitToolName,
+		ProcessWaitToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input ProcessWaitInput) (*mcp.CallToolResult, ProcessWaitOutput, error) {
+			if input.ProcessID == "" {
+				return nil, ProcessWaitOutput{}, fmt.Errorf("process_id is required")
+			}
+
+			timeout := input.TimeoutSeconds
+			if timeout <= 0 {
+				timeout = defaultProcessWaitSeconds
+			}
+
+			info, exited, err := processes.Manager().Wait(ctx, input.ProcessID, processes.Owner(req), time.Duration(timeout)*time.Second)
+			if err != nil {
+				return nil, ProcessWaitOutput{}, err
+			}
+
+			text := formatProcessStatus(info)
+			if !exited {
+				text = fmt.Sprintf("Still running after %ds\n%s", timeout, text)
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: text},
+				},
+			}, ProcessWaitOutput{Process: info, TimedOut: !exited}, nil
+		},
+	)
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// BackgroundProcesses scopes the process manager to client sessions: each
+// session only sees the processes it started, and they are stopped when the
+// session closes.
+type BackgroundProcesses struct {
+	manager *runners.ProcessManager
+
+	mu      sync.Mutex
+	watched map[*mcp.ServerSession]bool
+}
+
+func NewBackgroundProcesses(manager *runners.ProcessManager) *BackgroundProcesses {
+	return &BackgroundProcesses{
+		manager: manager,
+		watched: make(map[*mcp.ServerSession]bool),
+	}
+}
+
+func (b *BackgroundProcesses) Manager() *runners.ProcessManager {
+	return b.manager
+}
+
+// Owner returns the owner key for the request's session.
+func (b *BackgroundProcesses) Owner(req *mcp.CallToolRequest) any {
+	session := sessionOf(req)
+	if session == nil {
+		return session
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.watched[session] {
+		b.watched[session] = true
+		onSessionClose(session, func() {
+			b.manager.StopOwner(session)
+
+			b.mu.Lock()
+			delete(b.watched, session)
+			b.mu.Unlock()
+		})
+	}
+	return session
+}
+
+func formatProcessStatus(info runners.ProcessInfo) string {
+	line := fmt.Sprintf("Process %s (pid %d): %s", info.ID, info.PID, info.Status)
+	switch {
+	case info.ExitCode != nil:
+		line += fmt.Sprintf(", exit code %d", *info.ExitCode)
+	case info.Signal != "":
+		line += fmt.Sprintf(" by %s", info.Signal)
+	}
+	return line + "\nCommand: " + formatCommandLine(info.Command, info.Args)
+}
+
+func formatProcessList(infos []runners.ProcessInfo) string {
+	if len(infos) == 0 {
+		return "No background processes"
+	}
+	parts := make([]string, len(infos))
+	for i, info := range infos {
+		parts[i] = formatProcessStatus(info)
+	}
+	return strings.Join(parts, "\n\n")
+}
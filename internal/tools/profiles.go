@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+)
+
+const (
+	ProfileFull     = "full"
+	ProfileReadOnly = "readonly"
+	ProfileNoExec   = "no-exec"
+)
+
+var readOnlyTools = []string{
+	GrepToolName,
+	GlobToolName,
+	ReadToolName,
+	ListDirToolName,
+	TreeToolName,
+	GitStatusToolName,
+	GitLogToolName,
+	GitDiffToolName,
+	GitShowToolName,
+	GitBranchToolName,
+}
+
+var execTools = []string{
+	RunToolName,
+	RunOutputToolName,
+	ProcessOutputToolName,
+	ProcessListToolName,
+	ProcessSignalToolName,
+	ProcessWaitToolName,
+	ProcessInputToolName,
+}
+
+// selectTools returns the subset of available tool names to register. The
+// profile is a ceiling: tools.enabled can narrow it but never widen it, so a
+// readonly server stays read-only whatever else the config says.
+func selectTools(available []string, cfg config.ToolsConfig) (map[string]bool, error) {
+	known := make(map[string]bool, len(available))
+	for _, name := range available {
+		known[name] = true
+	}
+
+	selected := make(map[string]bool)
+	switch cfg.Profile {
+	case "", ProfileFull:
+		for _, name := range available {
+			selected[name] = true
+		}
+	case ProfileReadOnly:
+		for _, name := range readOnlyTools {
+			selected[name] = true
+		}
+	case ProfileNoExec:
+		for _, name := range available {
+			selected[name] = true
+		}
+		for _, name := range execTools {
+			delete(selected, name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown tools profile: %s (expected %s, %s or %s)", cfg.Profile, ProfileFull, ProfileReadOnly, ProfileNoExec)
+	}
+
+	for _, list := range [][]string{cfg.Enabled, cfg.Disabled} {
+		var unknown []string
+		for _, name := range list {
+			if !known[name] {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			return nil, fmt.Errorf("unknown tools in config: %s", strings.Join(unknown, ", "))
+		}
+	}
+
+	if len(cfg.Enabled) > 0 {
+		enabled := make(map[string]bool, len(cfg.Enabled))
+		for _, name := range cfg.Enabled {
+			enabled[name] = true
+		}
+		for name := range selected {
+			if !enabled[name] {
+				delete(selected, name)
+			}
+		}
+	}
+
+	for _, name := range cfg.Disabled {
+		delete(selected, name)
+	}
+
+	return selected, nil
+}
+
+func sortedNames(set map[string]bool) []string {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
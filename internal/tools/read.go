@@ -1,12 +1,17 @@
 package tools
 
 import (
-	"bufio"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -20,45 +25,105 @@ Usage:
 - You can optionally specify a line offset and limit (especially handy for long files), but it's recommended to read the whole file by not providing these parameters
 - Any lines longer than 2000 characters will be truncated
 - Results are returned using cat -n format, with line numbers starting at 1
-- This tool can only read files, not directories. To read a directory, use glob or bash ls`
+- This tool can only read files, not directories. To read a directory, use glob or bash ls
+- Binary files are refused in the default text mode. Use mode: "hex" or "base64" with byte_offset and byte_length to inspect them
+- PNG, JPEG, GIF and WebP images are returned as image content, scaled down to max_dimension pixels on the longest side when configured; images over 40 megapixels that would need scaling are refused
+- Jupyter notebooks (.ipynb) are rendered as ordered cells with their ids, types, sources and truncated text outputs. Use notebook_edit to change them`
 )
 
 type ReadInput struct {
-	FilePath string `json:"file_path" jsonschema:"required" jsonschema_description:"The absolute path to the file to read"`
-	Offset   int    `json:"offset,omitempty" jsonschema_description:"The line number to start reading from. Only provide if the file is too large to read at once"`
-	Limit    int    `json:"limit,omitempty" jsonschema_description:"The number of lines to read. Only provide if the file is too large to read at once."`
+	FilePath     string `json:"file_path" jsonschema:"required" jsonschema_description:"The absolute path to the file to read"`
+	Offset       int    `json:"offset,omitempty" jsonschema_description:"The line number to start reading from. Only provide if the file is too large to read at once"`
+	Limit        int    `json:"limit,omitempty" jsonschema_description:"The number of lines to read. Only provide if the file is too large to read at once."`
+	Mode         string `json:"mode,omitempty" jsonschema_description:"How to return the content: \"text\" (default), \"hex\" (hexdump with offsets) or \"base64\". Use hex or base64 for binary files."`
+	ByteOffset   int64  `json:"byte_offset,omitempty" jsonschema_description:"Byte offset to start reading from in hex or base64 mode."`
+	ByteLength   int    `json:"byte_length,omitempty" jsonschema_description:"Number of bytes to return in hex or base64 mode (default 4096, max 1048576)."`
+	MaxDimension int    `json:"max_dimension,omitempty" jsonschema_description:"For images, scale down so the longest side is at most this many pixels. Defaults to the server's configured limit."`
 }
 
 type ReadOutput struct {
-	Content    string `json:"content"`
-	LineCount  int    `json:"line_count"`
-	TotalLines int    `json:"total_lines"`
+	Content    string             `json:"content"`
+	LineCount  int                `json:"line_count"`
+	TotalLines int                `json:"total_lines"`
+	Encoding   string             `json:"encoding"`
+	BOM        bool               `json:"bom"`
+	LineEnding string             `json:"line_ending"`
+	Mode       string             `json:"mode"`
+	ByteOffset int64              `json:"byte_offset,omitempty"`
+	ByteLength int                `json:"byte_length,omitempty"`
+	FileSize   int64              `json:"file_size"`
+	MIMEType   string             `json:"mime_type,omitempty"`
+	Width      int                `json:"width,omitempty"`
+	Height     int                `json:"height,omitempty"`
+	Resized    bool               `json:"resized,omitempty"`
+	Cells      []ReadNotebookCell `json:"cells,omitempty"`
 }
 
-func NewReadTool() *ToolDefinition[ReadInput, ReadOutput] {
+type ReadNotebookCell struct {
+	Index          int      `json:"index"`
+	ID             string   `json:"id"`
+	CellType       string   `json:"cell_type"`
+	Source         string   `json:"source"`
+	ExecutionCount *int     `json:"execution_count,omitempty"`
+	Outputs        []string `json:"outputs,omitempty"`
+}
+
+func NewReadTool(guard *WorkspaceGuard, tracker *FileStateTracker, cfg config.ReadConfig) *ToolDefinition[ReadInput, ReadOutput] {
 	return NewToolDefinition(
 		ReadToolName,
 		ReadToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input ReadInput) (*mcp.CallToolResult, ReadOutput, error) {
-			file, err := os.Open(input.FilePath)
+			path, err := guard.Resolve(ctx, req, input.FilePath)
+			if err != nil {
+				return nil, ReadOutput{}, err
+			}
+
+			info, err := os.Stat(path)
 			if err != nil {
 				if os.IsNotExist(err) {
 					return nil, ReadOutput{}, fmt.Errorf("file does not exist: %s", input.FilePath)
 				}
 				return nil, ReadOutput{}, fmt.Errorf("failed to open file: %w", err)
 			}
-			defer file.Close()
 
-			totalLines := 0
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				totalLines++
+			if info.IsDir() {
+				return nil, ReadOutput{}, fmt.Errorf("path is a directory, not a file: %s", input.FilePath)
 			}
-			if err := scanner.Err(); err != nil {
-				return nil, ReadOutput{}, fmt.Errorf("failed to count lines: %w", err)
+
+			switch input.Mode {
+			case "", "text":
+			case "hex", "base64":
+				return readBytes(path, info.Size(), input)
+			default:
+				return nil, ReadOutput{}, fmt.Errorf("invalid mode: %s (expected text, hex or base64)", input.Mode)
 			}
 
-			file.Seek(0, 0)
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, ReadOutput{}, fmt.Errorf("failed to open file: %w", err)
+			}
+
+			switch runners.SniffContent(data) {
+			case runners.ContentImage:
+				maxDimension := cfg.ImageMaxDimension
+				if input.MaxDimension > 0 {
+					maxDimension = input.MaxDimension
+				}
+				return readImage(data, info.Size(), maxDimension)
+			case runners.ContentBinary:
+				return nil, ReadOutput{}, fmt.Errorf("file appears to be binary (%d bytes): %s. Use mode: \"hex\" or \"base64\" with byte_offset/byte_length to inspect it", info.Size(), input.FilePath)
+			}
+
+			if strings.EqualFold(filepath.Ext(path), ".ipynb") {
+				if notebook, err := runners.ParseNotebook(data); err == nil {
+					tracker.Record(req, path, info.ModTime(), data)
+					return readNotebook(notebook, info.Size())
+				}
+			}
+
+			text, format := runners.DecodeText(data)
+			allLines := splitLines(text)
+			totalLines := len(allLines)
 
 			offset := input.Offset
 			if offset < 0 {
@@ -69,29 +134,15 @@ func NewReadTool() *ToolDefinition[ReadInput, ReadOutput] {
 				limit = 2000
 			}
 
-			scanner = bufio.NewScanner(file)
 			var lines []string
-			lineNum := 1
-
-			for lineNum <= offset && scanner.Scan() {
-				lineNum++
-			}
-
-			readCount := 0
-			for scanner.Scan() && readCount < limit {
-				line := scanner.Text()
+			for i := offset; i < totalLines && len(lines) < limit; i++ {
+				line := allLines[i]
 
-				if len(line) > 2000 {
-					line = line[:2000] + "... (truncated)"
+				if truncated, ok := truncateRunes(line, 2000); ok {
+					line = truncated + "... (truncated)"
 				}
 
-				lines = append(lines, fmt.Sprintf("%6d→%s", lineNum, line))
-				lineNum++
-				readCount++
-			}
-
-			if err := scanner.Err(); err != nil {
-				return nil, ReadOutput{}, fmt.Errorf("failed to read file: %w", err)
+				lines = append(lines, fmt.Sprintf("%6d→%s", i+1, line))
 			}
 
 			content := strings.Join(lines, "\n")
@@ -100,10 +151,17 @@ func NewReadTool() *ToolDefinition[ReadInput, ReadOutput] {
 				content = "(empty file)"
 			}
 
+			tracker.Record(req, path, info.ModTime(), data)
+
 			output := ReadOutput{
 				Content:    content,
 				LineCount:  len(lines),
 				TotalLines: totalLines,
+				Encoding:   format.Encoding,
+				BOM:        format.BOM,
+				LineEnding: format.LineEnding,
+				Mode:       "text",
+				FileSize:   info.Size(),
 			}
 
 			return &mcp.CallToolResult{
@@ -114,3 +172,184 @@ func NewReadTool() *ToolDefinition[ReadInput, ReadOutput] {
 		},
 	)
 }
+
+func readNotebook(notebook *runners.Notebook, size int64) (*mcp.CallToolResult, ReadOutput, error) {
+	content := notebook.Render()
+
+	cells := notebook.Cells()
+	outputCells := make([]ReadNotebookCell, len(cells))
+	for i, cell := range cells {
+		outputCells[i] = ReadNotebookCell{
+			Index:          cell.Index,
+			ID:             cell.Ref(),
+			CellType:       cell.CellType,
+			Source:         cell.Source,
+			ExecutionCount: cell.ExecutionCount,
+			Outputs:        cell.Outputs,
+		}
+	}
+
+	output := ReadOutput{
+		Content:  content,
+		Mode:     "notebook",
+		FileSize: size,
+		Encoding: runners.EncodingUTF8,
+		Cells:    outputCells,
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: content},
+		},
+	}, output, nil
+}
+
+func readImage(data []byte, size int64, maxDimension int) (*mcp.CallToolResult, ReadOutput, error) {
+	img, err := runners.PrepareImage(data, runners.SniffImageType(data), maxDimension)
+	if err != nil {
+		return nil, ReadOutput{}, err
+	}
+
+	summary := fmt.Sprintf("Image %s, %dx%d", img.MIMEType, img.Width, img.Height)
+	if img.Resized {
+		summary += fmt.Sprintf(" (scaled down from %dx%d)", img.OriginalWidth, img.OriginalHeight)
+	}
+
+	output := ReadOutput{
+		Content:  summary,
+		Mode:     "image",
+		FileSize: size,
+		MIMEType: img.MIMEType,
+		Width:    img.Width,
+		Height:   img.Height,
+		Resized:  img.Resized,
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.ImageContent{Data: img.Data, MIMEType: img.MIMEType},
+			&mcp.TextContent{Text: summary},
+		},
+	}, output, nil
+}
+
+func readBytes(path string, size int64, input ReadInput) (*mcp.CallToolResult, ReadOutput, error) {
+	offset := input.ByteOffset
+	if offset < 0 || offset > size {
+		return nil, ReadOutput{}, fmt.Errorf("byte_offset %d is outside the file (size %d bytes)", offset, size)
+	}
+
+	length := input.ByteLength
+	if length <= 0 {
+		length = 4096
+	}
+	if length > 1<<20 {
+		length = 1 << 20
+	}
+	if remaining := size - offset; int64(length) > remaining {
+		length = int(remaining)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, ReadOutput{}, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, length)
+	n, err := file.ReadAt(buf, offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, ReadOutput{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	buf = buf[:n]
+
+	var content string
+	if input.Mode == "hex" {
+		content = formatHexDump(buf, offset)
+	} else {
+		content = base64.StdEncoding.EncodeToString(buf)
+	}
+
+	output := ReadOutput{
+		Content:    content,
+		Mode:       input.Mode,
+		ByteOffset: offset,
+		ByteLength: n,
+		FileSize:   size,
+	}
+
+	summary := fmt.Sprintf("Bytes %d-%d of %d (%s):\n%s", offset, offset+int64(n), size, input.Mode, content)
+	if n == 0 {
+		summary = fmt.Sprintf("No bytes at offset %d (file size %d)", offset, size)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: summary},
+		},
+	}, output, nil
+}
+
+func formatHexDump(data []byte, baseOffset int64) string {
+	var builder strings.Builder
+	for i := 0; i < len(data); i += 16 {
+		end := min(i+16, len(data))
+		row := data[i:end]
+
+		builder.WriteString(fmt.Sprintf("%08x  ", baseOffset+int64(i)))
+		for j := 0; j < 16; j++ {
+			if j < len(row) {
+				builder.WriteString(fmt.Sprintf("%02x ", row[j]))
+			} else {
+				builder.WriteString("   ")
+			}
+			if j == 7 {
+				builder.WriteString(" ")
+			}
+		}
+
+		builder.WriteString(" |")
+		for _, b := range row {
+			if b >= 0x20 && b < 0x7F {
+				builder.WriteByte(b)
+			} else {
+				builder.WriteByte('.')
+			}
+		}
+		builder.WriteString("|\n")
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	sep := "\n"
+	if runners.DetectLineEnding(text) == runners.LineEndingCR {
+		sep = "\r"
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, sep), sep)
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// truncateRunes cuts s to its first n runes and reports whether anything was
+// cut.
+func truncateRunes(s string, n int) (string, bool) {
+	if len(s) <= n {
+		return s, false
+	}
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i], true
+		}
+		count++
+	}
+	return s, false
+}
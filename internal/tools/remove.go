@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -23,12 +24,23 @@ type RemoveOutput struct {
 	Message string `json:"message"`
 }
 
-func NewRemoveTool(runner *runners.FileRunner) *ToolDefinition[RemoveInput, RemoveOutput] {
+func NewRemoveTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[RemoveInput, RemoveOutput] {
 	return NewToolDefinition(
 		RemoveToolName,
 		RemoveToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input RemoveInput) (*mcp.CallToolResult, RemoveOutput, error) {
-			if err := runner.Remove(ctx, runners.RemoveInput{Path: input.Path, Recursive: input.Recursive}); err != nil {
+			path, err := guard.Resolve(ctx, req, input.Path)
+			if err != nil {
+				return nil, RemoveOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			if guard.IsRoot(ctx, req, path) {
+				return nil, RemoveOutput{}, fmt.Errorf("refusing to remove workspace root: %s", path)
+			}
+
+			if err := runner.Remove(ctx, runners.RemoveInput{Path: path, Recursive: input.Recursive}); err != nil {
 				return nil, RemoveOutput{}, err
 			}
 
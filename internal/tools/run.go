@@ -3,45 +3,166 @@ package tools
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/google/jsonschema-go/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
 	RunToolName        = "run"
-	RunToolDescription = `Executes shell commands or scripts. Use with caution; output includes stdout, stderr, and exit code.`
+	RunToolDescription = `Executes shell commands or scripts. Use with caution; output includes stdout, stderr, and exit code.
+
+By default command is executed directly with args, without a shell. Set shell: true to run command as a script through the server's shell instead, which allows pipes, &&, globs and redirects. Shell runs behave like one terminal per session: the working directory after cd and variables set with export carry over to the next shell run.
+
+Set background: true for long-running commands such as dev servers, watchers or test loops. run then returns a process_id immediately instead of waiting; read output with process_output, check on it with process_list and process_wait, and stop it with process_signal. Background shell runs start from the session's directory and exports but do not change them. Background processes are stopped when the session ends.
+
+When the call carries a progress token, output is streamed while the command runs as notifications/progress, with the new stdout and stderr in _meta.stdout and _meta.stderr and the elapsed time in _meta.elapsed_ms. Cancelling the call kills the command and everything it started.
+
+Set pty: true to run the command on a pseudo-terminal of rows x cols (default 24x80), for interactive installers, colourised test runners or git add -p. stdout and stderr are merged into stdout; use strip_ansi: true to drop colours and other escape sequences. Combine with background: true and answer prompts with process_input. pty is available on Linux and macOS.
+
+Each of stdout and stderr is capped; longer output keeps its beginning and end around an elided-middle marker, and the result's "truncated" object gives an output_id for reading the full output with run_output.
+
+Commands are checked against the server's run policy first. A denied command returns an error with a "denied" object naming the matching rule and reason; some commands may require the user to confirm them before they run.`
 )
 
 type RunInput struct {
-	Command        string            `json:"command" jsonschema:"required" jsonschema_description:"Executable or script to run."`
+	Command        string            `json:"command" jsonschema:"required" jsonschema_description:"Executable or script to run. With shell: true, a full shell command line."`
 	Args           []string          `json:"args,omitempty" jsonschema_description:"Arguments to pass to the command."`
 	WorkingDir     string            `json:"working_dir,omitempty" jsonschema_description:"Directory to run the command in."`
 	TimeoutSeconds int               `json:"timeout_seconds,omitempty" jsonschema_description:"Timeout in seconds before the command is cancelled."`
 	Stdin          string            `json:"stdin,omitempty" jsonschema_description:"Optional standard input data."`
 	Env            map[string]string `json:"env,omitempty" jsonschema_description:"Additional environment variables (KEY: VALUE)."`
+	Shell          bool              `json:"shell,omitempty" jsonschema_description:"Run command through the shell, keeping the working directory and exported variables across calls in this session. args must be empty."`
+	Background     bool              `json:"background,omitempty" jsonschema_description:"Start the command in the background and return its process_id without waiting. timeout_seconds, if set, limits how long it may run."`
+	InheritEnv     *bool             `json:"inherit_env,omitempty" jsonschema_description:"Set to false for a hermetic run that inherits nothing from the server's environment; only env and variables set by the server's env policy are passed. Not allowed with shell: true."`
+	Pty            bool              `json:"pty,omitempty" jsonschema_description:"Run the command on a pseudo-terminal, for programs that behave differently or wait for input without one. stdout and stderr are merged into stdout."`
+	Rows           int               `json:"rows,omitempty" jsonschema_description:"Terminal height with pty: true (default 24)."`
+	Cols           int               `json:"cols,omitempty" jsonschema_description:"Terminal width with pty: true (default 80)."`
+	StripANSI      bool              `json:"strip_ansi,omitempty" jsonschema_description:"Remove colours and other terminal escape sequences from the output."`
 }
 
 type RunOutput struct {
-	Stdout   string `json:"stdout"`
-	Stderr   string `json:"stderr"`
-	ExitCode int    `json:"exit_code"`
+	Stdout     string                    `json:"stdout"`
+	Stderr     string                    `json:"stderr"`
+	ExitCode   int                       `json:"exit_code"`
+	WorkingDir string                    `json:"working_dir,omitempty"`
+	ProcessID  string                    `json:"process_id,omitempty"`
+	PID        int                       `json:"pid,omitempty"`
+	Denied     *RunPolicyDenial          `json:"denied,omitempty"`
+	Truncated  *runners.OutputTruncation `json:"truncated,omitempty"`
 }
 
-func NewRunTool() *ToolDefinition[RunInput, RunOutput] {
+type RunPolicyDenial struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Action  string   `json:"action"`
+	Rule    string   `json:"rule,omitempty"`
+	Reason  string   `json:"reason"`
+}
+
+func (o RunOutput) ExitStatus() int {
+	return o.ExitCode
+}
+
+func NewRunTool(policy *runners.CommandPolicy, envPolicy *runners.EnvPolicy, isolation *runners.Isolation, shells *ShellSessions, processes *BackgroundProcesses, spool *runners.OutputSpool, cfg config.RunConfig) *ToolDefinition[RunInput, RunOutput] {
 	return NewToolDefinition(
 		RunToolName,
 		RunToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input RunInput) (*mcp.CallToolResult, RunOutput, error) {
-			result, err := runners.RunCommand(ctx, runners.RunCommandInput{
-				Command:        input.Command,
-				Args:           input.Args,
-				WorkingDir:     input.WorkingDir,
-				TimeoutSeconds: input.TimeoutSeconds,
-				Stdin:          input.Stdin,
-				Env:            input.Env,
-			})
+			if input.Command == "" {
+				return nil, RunOutput{}, fmt.Errorf("command is required")
+			}
+
+			if input.Shell && len(input.Args) > 0 {
+				return nil, RunOutput{}, fmt.Errorf("args cannot be used with shell: true; put the whole command line in command")
+			}
+
+			inherit := input.InheritEnv == nil || *input.InheritEnv
+			if input.Shell && !inherit {
+				return nil, RunOutput{}, fmt.Errorf("inherit_env: false cannot be used with shell: true; shell runs keep the session's environment")
+			}
+
+			// Shell scripts are checked command by command, as well as the shell
+			// invocation that runs them (command: "bash", args: ["-c", "..."]).
+			// Direct commands are looked at through wrappers such as env.
+			policyCommand, policyArgs := input.Command, input.Args
+			if input.Shell {
+				policyCommand, policyArgs = shells.Shell(), []string{"-c", input.Command}
+			}
+
+			var decision runners.PolicyDecision
+			if script, ok := runners.ShellScript(policyCommand, policyArgs); ok {
+				decision = policy.EvaluateScript(policyCommand, policyArgs, script)
+			} else {
+				decision = policy.EvaluateCommand(policyCommand, policyArgs)
+			}
+			if decision.Action == runners.PolicyConfirm {
+				decision = confirmRun(ctx, req, policyCommand, policyArgs, input.WorkingDir, decision)
+			}
+			logRunDecision(ctx, policyCommand, policyArgs, decision)
+
+			if decision.Action != runners.PolicyAllow {
+				return deniedRun(policyCommand, policyArgs, decision)
+			}
+
+			if input.Rows < 0 || input.Cols < 0 {
+				return nil, RunOutput{}, fmt.Errorf("rows and cols must not be negative")
+			}
+			var pty *runners.PtyOptions
+			if input.Pty {
+				pty = &runners.PtyOptions{Rows: input.Rows, Cols: input.Cols}
+			}
+
+			if input.Background {
+				return startBackgroundRun(ctx, req, input, envPolicy, inherit, isolation, pty, shells, processes)
+			}
+
+			progress := startRunProgress(ctx, req)
+
+			var result runners.RunCommandResult
+			var err error
+			if input.Shell {
+				result, err = runners.RunShell(ctx, shells.Get(req), runners.RunShellInput{
+					Shell:          shells.Shell(),
+					Script:         input.Command,
+					WorkingDir:     input.WorkingDir,
+					TimeoutSeconds: input.TimeoutSeconds,
+					Stdin:          input.Stdin,
+					Env:            input.Env,
+					MaxOutputBytes: cfg.MaxOutputBytes,
+					Spool:          spool,
+					Isolation:      isolation,
+					OnOutput:       progress.OnOutput(),
+					Pty:            pty,
+					StripANSI:      input.StripANSI,
+				})
+			} else {
+				result, err = runners.RunCommand(ctx, runners.RunCommandInput{
+					Command:        input.Command,
+					Args:           input.Args,
+					WorkingDir:     input.WorkingDir,
+					TimeoutSeconds: input.TimeoutSeconds,
+					Stdin:          input.Stdin,
+					Env:            envPolicy.Environ(input.Command, inherit, input.Env),
+					MaxOutputBytes: cfg.MaxOutputBytes,
+					Spool:          spool,
+					Isolation:      isolation,
+					OnOutput:       progress.OnOutput(),
+					Pty:            pty,
+					StripANSI:      input.StripANSI,
+				})
+			}
+			progress.Stop()
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				// The process group was killed because the client cancelled the
+				// call or went away; there is no result to report.
+				return nil, RunOutput{}, fmt.Errorf("command cancelled: %w", ctxErr)
+			}
 			if err != nil {
 				return nil, RunOutput{}, err
 			}
@@ -49,9 +170,11 @@ func NewRunTool() *ToolDefinition[RunInput, RunOutput] {
 			summary := formatRunSummary(result)
 
 			output := RunOutput{
-				Stdout:   result.Stdout,
-				Stderr:   result.Stderr,
-				ExitCode: result.ExitCode,
+				Stdout:     result.Stdout,
+				Stderr:     result.Stderr,
+				ExitCode:   result.ExitCode,
+				WorkingDir: result.WorkingDir,
+				Truncated:  result.Truncated,
 			}
 
 			return &mcp.CallToolResult{
@@ -63,6 +186,157 @@ func NewRunTool() *ToolDefinition[RunInput, RunOutput] {
 	)
 }
 
+func startBackgroundRun(ctx context.Context, req *mcp.CallToolRequest, input RunInput, envPolicy *runners.EnvPolicy, inherit bool, isolation *runners.Isolation, pty *runners.PtyOptions, shells *ShellSessions, processes *BackgroundProcesses) (*mcp.CallToolResult, RunOutput, error) {
+	start := runners.StartProcessInput{
+		Command:        input.Command,
+		Args:           input.Args,
+		WorkingDir:     input.WorkingDir,
+		TimeoutSeconds: input.TimeoutSeconds,
+		Stdin:          input.Stdin,
+		Owner:          processes.Owner(req),
+		Isolation:      isolation,
+		Pty:            pty,
+		StripANSI:      input.StripANSI,
+	}
+	if input.Shell {
+		dir, environ := shells.Get(req).Snapshot(input.Env)
+		start.Command, start.Args, start.Env = shells.Shell(), []string{"-c", input.Command}, environ
+		if start.WorkingDir == "" {
+			start.WorkingDir = dir
+		}
+	} else {
+		start.Env = envPolicy.Environ(input.Command, inherit, input.Env)
+	}
+
+	info, err := processes.Manager().Start(start)
+	if err != nil {
+		return nil, RunOutput{}, err
+	}
+	logger.InfoContext(ctx, "Background process started", "process_id", info.ID, "pid", info.PID, "command", info.Command)
+
+	output := RunOutput{
+		WorkingDir: info.WorkingDir,
+		ProcessID:  info.ID,
+		PID:        info.PID,
+	}
+
+	message := fmt.Sprintf("Started background process %s (pid %d): %s\nRead its output with process_output.", info.ID, info.PID, formatCommandLine(info.Command, info.Args))
+	if info.Pty {
+		message += " Type into it with process_input."
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: message},
+		},
+	}, output, nil
+}
+
+func confirmRun(ctx context.Context, req *mcp.CallToolRequest, command string, args []string, workingDir string, decision runners.PolicyDecision) runners.PolicyDecision {
+	denied := runners.PolicyDecision{Action: runners.PolicyDeny, Rule: decision.Rule}
+
+	if req == nil || req.Session == nil {
+		denied.Reason = "command requires confirmation, but there is no client session to ask"
+		return denied
+	}
+	params := req.Session.InitializeParams()
+	if params == nil || params.Capabilities == nil || params.Capabilities.Elicitation == nil {
+		denied.Reason = "command requires confirmation, but the client does not support elicitation"
+		return denied
+	}
+
+	message := fmt.Sprintf("Allow running: %s", formatCommandLine(command, args))
+	if workingDir != "" {
+		message += fmt.Sprintf("\nWorking directory: %s", workingDir)
+	}
+	if decision.Reason != "" {
+		message += fmt.Sprintf("\nPolicy: %s", decision.Reason)
+	}
+
+	result, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+		Message: message,
+		RequestedSchema: &jsonschema.Schema{
+			Type: "object",
+			Properties: map[string]*jsonschema.Schema{
+				"approve": {Type: "boolean", Description: "Run this command"},
+			},
+			Required: []string{"approve"},
+		},
+	})
+	if err != nil {
+		denied.Reason = fmt.Sprintf("confirmation request failed: %v", err)
+		return denied
+	}
+
+	if result.Action != "accept" {
+		denied.Reason = fmt.Sprintf("user did not confirm the command (%s)", result.Action)
+		return denied
+	}
+	if approve, _ := result.Content["approve"].(bool); !approve {
+		denied.Reason = "user did not approve the command"
+		return denied
+	}
+
+	return runners.PolicyDecision{Action: runners.PolicyAllow, Rule: decision.Rule, Reason: "confirmed by user"}
+}
+
+func logRunDecision(ctx context.Context, command string, commandArgs []string, decision runners.PolicyDecision) {
+	args := []any{
+		"command", command,
+		"args", commandArgs,
+		"action", decision.Action,
+		"rule", decision.Rule,
+		"reason", decision.Reason,
+	}
+	if decision.Action == runners.PolicyAllow {
+		logger.InfoContext(ctx, "Run policy decision", args...)
+	} else {
+		logger.WarnContext(ctx, "Run policy decision", args...)
+	}
+}
+
+func deniedRun(command string, args []string, decision runners.PolicyDecision) (*mcp.CallToolResult, RunOutput, error) {
+	if decision.Reason == "" && decision.Rule != "" {
+		decision.Reason = "command matches a deny rule"
+	} else if decision.Reason == "" {
+		decision.Reason = "commands not matched by any rule are denied"
+	}
+
+	denial := &RunPolicyDenial{
+		Command: command,
+		Args:    args,
+		Action:  decision.Action,
+		Rule:    decision.Rule,
+		Reason:  decision.Reason,
+	}
+
+	message := "Command denied by run policy"
+	if decision.Rule != "" {
+		message += fmt.Sprintf(" (rule %s)", decision.Rule)
+	} else {
+		message += " (default action)"
+	}
+	message += ": " + formatCommandLine(command, args)
+	message += "\nReason: " + decision.Reason
+
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: message},
+		},
+	}, RunOutput{ExitCode: -1, Denied: denial}, nil
+}
+
+func formatCommandLine(command string, args []string) string {
+	parts := append([]string{command}, args...)
+	for i, part := range parts {
+		if part == "" || strings.ContainsAny(part, " \t\n\"'\\$") {
+			parts[i] = strconv.Quote(part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
 func formatRunSummary(result runners.RunCommandResult) string {
 	stdout := result.Stdout
 	if stdout == "" {
@@ -76,6 +350,12 @@ func formatRunSummary(result runners.RunCommandResult) string {
 
 	var builder strings.Builder
 	builder.WriteString(fmt.Sprintf("Exit code: %d\n", result.ExitCode))
+	if result.WorkingDir != "" {
+		builder.WriteString(fmt.Sprintf("Working directory: %s\n", result.WorkingDir))
+	}
+	if result.Truncated != nil {
+		builder.WriteString(formatTruncation(result.Truncated))
+	}
 	builder.WriteString("Stdout:\n")
 	builder.WriteString(stdout)
 	builder.WriteString("\nStderr:\n")
@@ -83,3 +363,19 @@ func formatRunSummary(result runners.RunCommandResult) string {
 
 	return builder.String()
 }
+
+func formatTruncation(truncated *runners.OutputTruncation) string {
+	var streams []string
+	if truncated.StdoutTruncated {
+		streams = append(streams, fmt.Sprintf("stdout (%d bytes)", truncated.StdoutBytes))
+	}
+	if truncated.StderrTruncated {
+		streams = append(streams, fmt.Sprintf("stderr (%d bytes)", truncated.StderrBytes))
+	}
+
+	line := "Output truncated: " + strings.Join(streams, ", ")
+	if truncated.OutputID != "" {
+		line += fmt.Sprintf("; read the full output with run_output (output_id %s)", truncated.OutputID)
+	}
+	return line + "\n"
+}
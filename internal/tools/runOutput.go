@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	RunOutputToolName        = "run_output"
+	RunOutputToolDescription = `Reads the full output of a run whose stdout or stderr was truncated.
+
+Usage:
+- output_id comes from the "truncated" object in the run result
+- Read a stream in pages: start at offset 0 and pass next_offset back until eof is true
+- Only the most recent truncated runs are kept, and only the streams that were truncated`
+)
+
+type RunOutputInput struct {
+	OutputID string `json:"output_id" jsonschema:"required" jsonschema_description:"Output id from the truncated object of a run result."`
+	Stream   string `json:"stream,omitempty" jsonschema_description:"Stream to read: stdout (default) or stderr."`
+	Offset   int64  `json:"offset,omitempty" jsonschema_description:"Byte offset to start reading from."`
+	Length   int    `json:"length,omitempty" jsonschema_description:"Maximum bytes to return (default 32768, max 1048576)."`
+}
+
+type RunOutputOutput struct {
+	OutputID   string `json:"output_id"`
+	Stream     string `json:"stream"`
+	Offset     int64  `json:"offset"`
+	Content    string `json:"content"`
+	NextOffset int64  `json:"next_offset"`
+	TotalBytes int64  `json:"total_bytes"`
+	EOF        bool   `json:"eof"`
+}
+
+func NewRunOutputTool(spool *runners.OutputSpool) *ToolDefinition[RunOutputInput, RunOutputOutput] {
+	return NewToolDefinition(
+		RunOutputToolName,
+		RunOutputToolDescription,
+		func(ctx context.Context, req *mcp.CallToolRequest, input RunOutputInput) (*mcp.CallToolResult, RunOutputOutput, error) {
+			if input.OutputID == "" {
+				return nil, RunOutputOutput{}, fmt.Errorf("output_id is required")
+			}
+
+			stream := input.Stream
+			if stream == "" {
+				stream = runners.StreamStdout
+			}
+
+			chunk, err := spool.Read(input.OutputID, stream, input.Offset, input.Length)
+			if err != nil {
+				return nil, RunOutputOutput{}, err
+			}
+
+			start := chunk.NextOffset - int64(len(chunk.Content))
+			output := RunOutputOutput{
+				OutputID:   input.OutputID,
+				Stream:     stream,
+				Offset:     start,
+				Content:    chunk.Content,
+				NextOffset: chunk.NextOffset,
+				TotalBytes: chunk.TotalBytes,
+				EOF:        chunk.EOF,
+			}
+
+			header := fmt.Sprintf("%s bytes %d-%d of %d", stream, start, chunk.NextOffset, chunk.TotalBytes)
+			if !chunk.EOF {
+				header += fmt.Sprintf(" (continue with offset %d)", chunk.NextOffset)
+			}
+
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{
+					&mcp.TextContent{Text: header + "\n" + chunk.Content},
+				},
+			}, output, nil
+		},
+	)
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	progressInterval  = 500 * time.Millisecond
+	progressHeartbeat = 5 * time.Second
+	// progressMaxChunk caps the output carried by a single notification per
+	// stream; older pending output is skipped when a command outpaces it.
+	progressMaxChunk = 16 * 1024
+)
+
+// runProgress streams a run's output to the client as notifications/progress
+// while the command runs. Each notification carries the output written since
+// the previous one in _meta.stdout and _meta.stderr, the elapsed time in
+// _meta.elapsed_ms, and the elapsed seconds as progress.
+type runProgress struct {
+	session *mcp.ServerSession
+	token   any
+	start   time.Time
+
+	mu       sync.Mutex
+	stdout   pendingOutput
+	stderr   pendingOutput
+	last     float64
+	lastSent time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type pendingOutput struct {
+	data    []byte
+	skipped int
+}
+
+func (o *pendingOutput) add(chunk []byte) {
+	o.data = append(o.data, chunk...)
+	if over := len(o.data) - progressMaxChunk; over > 0 {
+		kept := runners.TrimLeadingPartialRune(o.data[over:])
+		o.skipped += len(o.data) - len(kept)
+		o.data = append([]byte(nil), kept...)
+	}
+}
+
+// take returns the pending output, keeping back a character whose remaining
+// bytes have not been written yet.
+func (o *pendingOutput) take() string {
+	complete := runners.TrimPartialRune(o.data)
+	text := string(complete)
+	if o.skipped > 0 {
+		text = fmt.Sprintf("[%d bytes skipped]\n%s", o.skipped, text)
+	}
+	o.data, o.skipped = append([]byte(nil), o.data[len(complete):]...), 0
+	return text
+}
+
+// startRunProgress returns nil when the call has no progress token, in which
+// case the run is not streamed.
+func startRunProgress(ctx context.Context, req *mcp.CallToolRequest) *runProgress {
+	if req == nil || req.Session == nil || req.Params == nil {
+		return nil
+	}
+	token := req.Params.GetProgressToken()
+	if token == nil {
+		return nil
+	}
+
+	p := &runProgress{
+		session: req.Session,
+		token:   token,
+		start:   time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	p.lastSent = p.start
+	go p.loop(ctx)
+	return p
+}
+
+func (p *runProgress) OnOutput() runners.OutputFunc {
+	if p == nil {
+		return nil
+	}
+	return func(stream string, chunk []byte) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if stream == runners.StreamStderr {
+			p.stderr.add(chunk)
+		} else {
+			p.stdout.add(chunk)
+		}
+	}
+}
+
+func (p *runProgress) loop(ctx context.Context) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.flush(ctx)
+		case <-p.stop:
+			p.flush(ctx)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Stop sends any output not yet reported and ends streaming.
+func (p *runProgress) Stop() {
+	if p == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+}
+
+func (p *runProgress) flush(ctx context.Context) {
+	p.mu.Lock()
+	now := time.Now()
+	hasOutput := len(p.stdout.data) > 0 || len(p.stderr.data) > 0
+	if !hasOutput && now.Sub(p.lastSent) < progressHeartbeat {
+		p.mu.Unlock()
+		return
+	}
+
+	stdout, stderr := p.stdout.take(), p.stderr.take()
+	elapsed := now.Sub(p.start)
+	// Progress must increase with every notification.
+	progress := elapsed.Seconds()
+	if progress <= p.last {
+		progress = p.last + 0.001
+	}
+	p.last = progress
+	p.lastSent = now
+	p.mu.Unlock()
+
+	message := fmt.Sprintf("Running for %s", elapsed.Round(time.Second))
+	var parts []string
+	for _, text := range []string{stdout, stderr} {
+		if text = strings.TrimRight(text, "\n"); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) > 0 {
+		message = strings.Join(parts, "\n")
+	}
+
+	_ = p.session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+		Meta: mcp.Meta{
+			"stdout":     stdout,
+			"stderr":     stderr,
+			"elapsed_ms": elapsed.Milliseconds(),
+		},
+		ProgressToken: p.token,
+		Message:       message,
+		Progress:      progress,
+	})
+}
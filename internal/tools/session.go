@@ -0,0 +1,22 @@
+package tools
+
+import "github.com/modelcontextprotocol/go-sdk/mcp"
+
+func sessionOf(req *mcp.CallToolRequest) *mcp.ServerSession {
+	if req == nil {
+		return nil
+	}
+	return req.Session
+}
+
+// onSessionClose runs cleanup in the background once session has closed, to
+// drop per-session state. A nil session never closes, so nothing is run.
+func onSessionClose(session *mcp.ServerSession, cleanup func()) {
+	if session == nil {
+		return
+	}
+	go func() {
+		session.Wait()
+		cleanup()
+	}()
+}
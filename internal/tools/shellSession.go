@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"os"
+	"sync"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ShellSessions keeps one runners.ShellSession per client session so that
+// shell-mode runs see the directory and exports left by earlier ones.
+type ShellSessions struct {
+	shell     string
+	envPolicy *runners.EnvPolicy
+
+	mu       sync.Mutex
+	sessions map[*mcp.ServerSession]*runners.ShellSession
+}
+
+func NewShellSessions(shell string, envPolicy *runners.EnvPolicy) *ShellSessions {
+	if shell == "" {
+		shell = runners.DefaultShell()
+	}
+	return &ShellSessions{
+		shell:     shell,
+		envPolicy: envPolicy,
+		sessions:  make(map[*mcp.ServerSession]*runners.ShellSession),
+	}
+}
+
+func (s *ShellSessions) Shell() string {
+	return s.shell
+}
+
+func (s *ShellSessions) Get(req *mcp.CallToolRequest) *runners.ShellSession {
+	session := sessionOf(req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shell, ok := s.sessions[session]
+	if !ok {
+		dir, _ := os.Getwd()
+		shell = runners.NewShellSession(dir, s.envPolicy.Environ(s.shell, true, nil))
+		s.sessions[session] = shell
+		onSessionClose(session, func() {
+			s.mu.Lock()
+			delete(s.sessions, session)
+			s.mu.Unlock()
+		})
+	}
+	return shell
+}
@@ -2,7 +2,10 @@ package tools
 
 import (
 	"context"
+	"sync"
+	"time"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -25,15 +28,56 @@ func NewToolDefinition[TInput, TOutput any](
 	}
 }
 
+func (td *ToolDefinition[TInput, TOutput]) Name() string {
+	return td.Tool.Name
+}
+
 func (td *ToolDefinition[TInput, TOutput]) Register(s *mcp.Server) {
 	wrappedHandler := func(ctx context.Context, req *mcp.CallToolRequest, input TInput) (*mcp.CallToolResult, TOutput, error) {
+		callID := logger.NewCallID()
+		ctx = logger.WithToolCall(ctx, req.Session, td.Tool.Name, callID)
+		start := time.Now()
+
+		ctx, call := audit.Begin(ctx, auditSessionID(req.Session), callID, td.Tool.Name, input)
 
 		result, output, err := td.Handler(ctx, req, input)
 
-		logger.LogToolCall(td.Tool.Name, input, output, err)
+		call.End(result, output, err)
+		logger.LogToolCall(ctx, start, err)
 
 		return result, output, err
 	}
 
 	mcp.AddTool(s, td.Tool, wrappedHandler)
 }
+
+// localSessions names sessions whose transport has no session id (stdio,
+// in-memory), so their audit entries can still be told apart by replay.
+var localSessions = struct {
+	sync.Mutex
+	ids map[*mcp.ServerSession]string
+}{ids: make(map[*mcp.ServerSession]string)}
+
+func auditSessionID(session *mcp.ServerSession) string {
+	if session == nil {
+		return ""
+	}
+	if id := session.ID(); id != "" {
+		return id
+	}
+
+	localSessions.Lock()
+	defer localSessions.Unlock()
+
+	id, ok := localSessions.ids[session]
+	if !ok {
+		id = "local-" + logger.NewCallID()
+		localSessions.ids[session] = id
+		onSessionClose(session, func() {
+			localSessions.Lock()
+			delete(localSessions.ids, session)
+			localSessions.Unlock()
+		})
+	}
+	return id
+}
@@ -2,32 +2,92 @@ package tools
 
 import (
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/config"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
 	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
-func RegisterTools(s *mcp.Server, cfg *config.Config) {
+type registrable interface {
+	Name() string
+	Register(s *mcp.Server)
+}
+
+func RegisterTools(s *mcp.Server, cfg *config.Config, guard *WorkspaceGuard, processManager *runners.ProcessManager, spool *runners.OutputSpool) error {
 
 	ripgrepRunner := runners.NewRipgrepRunner()
 	gitRunner := runners.NewGitRunner()
 	fileRunner := runners.NewFileRunner()
+	fileState := NewFileStateTracker()
+
+	runPolicy, err := runners.NewCommandPolicy(cfg.Run.Policy)
+	if err != nil {
+		return err
+	}
+
+	envPolicy, err := runners.NewEnvPolicy(cfg.Run.EnvPolicy)
+	if err != nil {
+		return err
+	}
+
+	isolation, err := runners.NewIsolation(cfg.Run, guard.Roots())
+	if err != nil {
+		return err
+	}
+	logger.Info("Run isolation configured", isolation.Describe()...)
+
+	processes := NewBackgroundProcesses(processManager)
+
+	all := []registrable{
+		NewGrepTool(ripgrepRunner, guard),
+		NewGlobTool(guard),
+		NewReadTool(guard, fileState, cfg.Read),
+		NewEditTool(guard, fileState),
+		NewMultiEditTool(guard, fileState),
+		NewWriteTool(guard, fileState),
+		NewApplyPatchTool(fileRunner, guard, fileState),
+		NewNotebookEditTool(guard, fileState),
+		NewGitStatusTool(gitRunner, guard),
+		NewGitLogTool(gitRunner, guard),
+		NewGitDiffTool(gitRunner, guard),
+		NewGitShowTool(gitRunner, guard),
+		NewGitBranchTool(gitRunner, guard),
+		NewListDirTool(fileRunner, guard),
+		NewDeleteTool(fileRunner, guard),
+		NewRemoveTool(fileRunner, guard),
+		NewCopyTool(fileRunner, guard),
+		NewMoveTool(fileRunner, guard),
+		NewTreeTool(fileRunner, guard),
+		NewRunTool(runPolicy, envPolicy, isolation, NewShellSessions(cfg.Run.Shell, envPolicy), processes, spool, cfg.Run),
+		NewRunOutputTool(spool),
+		NewProcessOutputTool(processes),
+		NewProcessListTool(processes),
+		NewProcessSignalTool(processes),
+		NewProcessWaitTool(processes),
+		NewProcessInputTool(processes),
+	}
+
+	available := make([]string, len(all))
+	for i, tool := range all {
+		available[i] = tool.Name()
+	}
+
+	selected, err := selectTools(available, cfg.Tools)
+	if err != nil {
+		return err
+	}
+
+	for _, tool := range all {
+		if selected[tool.Name()] {
+			tool.Register(s)
+		}
+	}
+
+	profile := cfg.Tools.Profile
+	if profile == "" {
+		profile = ProfileFull
+	}
+	logger.Info("Tools registered", "profile", profile, "tools", sortedNames(selected))
 
-	NewGrepTool(ripgrepRunner).Register(s)
-	NewGlobTool().Register(s)
-	NewReadTool().Register(s)
-	NewEditTool().Register(s)
-	NewWriteTool().Register(s)
-	NewGitStatusTool(gitRunner).Register(s)
-	NewGitLogTool(gitRunner).Register(s)
-	NewGitDiffTool(gitRunner).Register(s)
-	NewGitShowTool(gitRunner).Register(s)
-	NewGitBranchTool(gitRunner).Register(s)
-	NewListDirTool(fileRunner).Register(s)
-	NewDeleteTool(fileRunner).Register(s)
-	NewRemoveTool(fileRunner).Register(s)
-	NewCopyTool(fileRunner).Register(s)
-	NewMoveTool(fileRunner).Register(s)
-	NewTreeTool(fileRunner).Register(s)
-	NewRunTool().Register(s)
+	return nil
 }
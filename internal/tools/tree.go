@@ -23,13 +23,18 @@ type TreeOutput struct {
 	Tree string `json:"tree"`
 }
 
-func NewTreeTool(runner *runners.FileRunner) *ToolDefinition[TreeInput, TreeOutput] {
+func NewTreeTool(runner *runners.FileRunner, guard *WorkspaceGuard) *ToolDefinition[TreeInput, TreeOutput] {
 	return NewToolDefinition(
 		TreeToolName,
 		TreeToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input TreeInput) (*mcp.CallToolResult, TreeOutput, error) {
+			path, err := guard.Resolve(ctx, req, input.Path)
+			if err != nil {
+				return nil, TreeOutput{}, err
+			}
+
 			result, err := runner.Tree(ctx, runners.TreeInput{
-				Path:       input.Path,
+				Path:       path,
 				Depth:      input.Depth,
 				ShowHidden: input.ShowHidden,
 				Limit:      input.Limit,
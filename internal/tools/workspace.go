@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"sync"
+
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/logger"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type WorkspaceGuard struct {
+	workspace *runners.Workspace
+
+	mu          sync.Mutex
+	clientRoots map[*mcp.ServerSession][]string
+	watched     map[*mcp.ServerSession]bool
+}
+
+func NewWorkspaceGuard(workspace *runners.Workspace) *WorkspaceGuard {
+	return &WorkspaceGuard{
+		workspace:   workspace,
+		clientRoots: make(map[*mcp.ServerSession][]string),
+		watched:     make(map[*mcp.ServerSession]bool),
+	}
+}
+
+func (g *WorkspaceGuard) Roots() []string {
+	return g.workspace.Roots()
+}
+
+func (g *WorkspaceGuard) Resolve(ctx context.Context, req *mcp.CallToolRequest, path string) (string, error) {
+	return g.workspace.Resolve(path, g.sessionRoots(ctx, req))
+}
+
+func (g *WorkspaceGuard) ResolveAll(ctx context.Context, req *mcp.CallToolRequest, paths []string) ([]string, error) {
+	clientRoots := g.sessionRoots(ctx, req)
+	resolved := make([]string, len(paths))
+	for i, path := range paths {
+		p, err := g.workspace.Resolve(path, clientRoots)
+		if err != nil {
+			return nil, err
+		}
+		resolved[i] = p
+	}
+	return resolved, nil
+}
+
+func (g *WorkspaceGuard) RootOf(path string) string {
+	return g.workspace.RootOf(path)
+}
+
+func (g *WorkspaceGuard) IsRoot(ctx context.Context, req *mcp.CallToolRequest, path string) bool {
+	return g.workspace.IsRoot(path, g.sessionRoots(ctx, req))
+}
+
+func (g *WorkspaceGuard) RootsChanged(ctx context.Context, req *mcp.RootsListChangedRequest) {
+	g.mu.Lock()
+	delete(g.clientRoots, req.Session)
+	g.mu.Unlock()
+}
+
+func (g *WorkspaceGuard) sessionRoots(ctx context.Context, req *mcp.CallToolRequest) []string {
+	if req == nil || req.Session == nil {
+		return nil
+	}
+
+	g.mu.Lock()
+	roots, ok := g.clientRoots[req.Session]
+	g.mu.Unlock()
+	if ok {
+		return roots
+	}
+
+	roots = []string{}
+	if advertisesRoots(req.Session) {
+		result, err := req.Session.ListRoots(ctx, nil)
+		if err != nil {
+			// Not cached, so the next call asks again.
+			logger.DebugContext(ctx, "Client roots unavailable, using configured workspace roots", "error", err)
+			return nil
+		}
+		for _, root := range result.Roots {
+			path, ok := rootPath(root.URI)
+			if !ok {
+				continue
+			}
+			resolved, err := runners.ResolveRoots([]string{path})
+			if err != nil {
+				continue
+			}
+			roots = append(roots, resolved...)
+		}
+	}
+
+	session := req.Session
+	g.mu.Lock()
+	if !g.watched[session] {
+		g.watched[session] = true
+		onSessionClose(session, func() {
+			g.mu.Lock()
+			delete(g.clientRoots, session)
+			delete(g.watched, session)
+			g.mu.Unlock()
+		})
+	}
+	g.clientRoots[session] = roots
+	g.mu.Unlock()
+
+	return roots
+}
+
+// advertisesRoots reports whether the client declared the roots capability.
+// The SDK decodes it into a plain struct, so only listChanged shows that it
+// was sent; clients that support roots set it.
+func advertisesRoots(session *mcp.ServerSession) bool {
+	params := session.InitializeParams()
+	return params != nil && params.Capabilities != nil && params.Capabilities.Roots.ListChanged
+}
+
+func rootPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/audit"
+	"github.com/AbdelilahOu/CodeToolsMcp/internal/runners"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
@@ -15,41 +17,93 @@ const (
 Usage:
 - This tool will overwrite the existing file if there is one at the provided path.
 - If this is an existing file, you MUST use the Read tool first to read the file's contents. This tool will fail if you did not read the file first.
-- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.`
+- ALWAYS prefer editing existing files in the codebase. NEVER write new files unless explicitly required.
+- When overwriting, the existing file's encoding, byte order mark and line endings are kept unless line_ending, encoding or bom say otherwise.`
 )
 
 type WriteInput struct {
-	FilePath string `json:"file_path" jsonschema:"required" jsonschema_description:"The absolute path to the file to write (must be absolute, not relative)"`
-	Content  string `json:"content" jsonschema:"required" jsonschema_description:"The content to write to the file"`
+	FilePath   string `json:"file_path" jsonschema:"required" jsonschema_description:"The absolute path to the file to write (must be absolute, not relative)"`
+	Content    string `json:"content" jsonschema:"required" jsonschema_description:"The content to write to the file"`
+	LineEnding string `json:"line_ending,omitempty" jsonschema_description:"Line ending style to write: \"preserve\" (default, keeps the existing file's style), \"lf\" or \"crlf\"."`
+	Encoding   string `json:"encoding,omitempty" jsonschema_description:"Encoding to write: \"utf-8\", \"utf-16le\", \"utf-16be\" or \"latin-1\". Defaults to the existing file's encoding, or utf-8 for new files."`
+	BOM        *bool  `json:"bom,omitempty" jsonschema_description:"Whether to write a byte order mark. Defaults to the existing file's choice, or false for new files."`
 }
 
 type WriteOutput struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Size    int64  `json:"size"`
+	Success    bool   `json:"success"`
+	Message    string `json:"message"`
+	Size       int64  `json:"size"`
+	Encoding   string `json:"encoding"`
+	BOM        bool   `json:"bom"`
+	LineEnding string `json:"line_ending"`
 }
 
-func NewWriteTool() *ToolDefinition[WriteInput, WriteOutput] {
+func NewWriteTool(guard *WorkspaceGuard, tracker *FileStateTracker) *ToolDefinition[WriteInput, WriteOutput] {
 	return NewToolDefinition(
 		WriteToolName,
 		WriteToolDescription,
 		func(ctx context.Context, req *mcp.CallToolRequest, input WriteInput) (*mcp.CallToolResult, WriteOutput, error) {
 
+			path, err := guard.Resolve(ctx, req, input.FilePath)
+			if err != nil {
+				return nil, WriteOutput{}, err
+			}
+
+			audit.TrackFiles(ctx, path)
+
+			format := runners.DefaultTextFormat()
+			lineEnding := ""
+
 			fileExists := false
-			if _, err := os.Stat(input.FilePath); err == nil {
+			if info, err := os.Stat(path); err == nil {
 				fileExists = true
+
+				current, err := os.ReadFile(path)
+				if err != nil {
+					return nil, WriteOutput{}, fmt.Errorf("failed to read existing file: %w", err)
+				}
+				if err := tracker.Check(req, path, info.ModTime(), current); err != nil {
+					return nil, WriteOutput{}, err
+				}
+
+				_, format = runners.DecodeText(current)
+				lineEnding = format.LineEnding
+			}
+
+			switch input.LineEnding {
+			case "", "preserve":
+			case runners.LineEndingLF, runners.LineEndingCRLF:
+				lineEnding = input.LineEnding
+			default:
+				return nil, WriteOutput{}, fmt.Errorf("invalid line_ending: %s", input.LineEnding)
+			}
+			if input.Encoding != "" {
+				format.Encoding = input.Encoding
+			}
+			if input.BOM != nil {
+				format.BOM = *input.BOM
+			}
+
+			text := runners.NormalizeLineEndings(input.Content, lineEnding)
+			format.LineEnding = runners.DetectLineEnding(text)
+
+			content, err := runners.EncodeText(text, format)
+			if err != nil {
+				return nil, WriteOutput{}, fmt.Errorf("failed to encode content as %s: %w", format.Encoding, err)
 			}
 
-			err := os.WriteFile(input.FilePath, []byte(input.Content), 0644)
+			err = runners.WriteFileAtomic(path, content, 0644)
 			if err != nil {
 				return nil, WriteOutput{}, fmt.Errorf("failed to write file: %w", err)
 			}
 
-			info, err := os.Stat(input.FilePath)
+			info, err := os.Stat(path)
 			if err != nil {
 				return nil, WriteOutput{}, fmt.Errorf("failed to get file info: %w", err)
 			}
 
+			tracker.Record(req, path, info.ModTime(), content)
+
 			var message string
 			if fileExists {
 				message = fmt.Sprintf("Successfully overwrote file: %s (%d bytes)", input.FilePath, info.Size())
@@ -58,9 +112,12 @@ func NewWriteTool() *ToolDefinition[WriteInput, WriteOutput] {
 			}
 
 			output := WriteOutput{
-				Success: true,
-				Message: message,
-				Size:    info.Size(),
+				Success:    true,
+				Message:    message,
+				Size:       info.Size(),
+				Encoding:   format.Encoding,
+				BOM:        format.BOM,
+				LineEnding: format.LineEnding,
 			}
 
 			return &mcp.CallToolResult{